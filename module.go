@@ -8,6 +8,7 @@ import (
 
 	"go.viam.com/rdk/components/board"
 	"go.viam.com/rdk/components/encoder"
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/module"
 	"go.viam.com/utils"
@@ -33,6 +34,26 @@ func mainWithArgs(ctx context.Context, args []string, logger logging.Logger) (er
 	if err != nil {
 		return err
 	}
+	err = customModule.AddModelFromRegistry(ctx, encoder.API, revolutionpi.QuadratureEncoderModel)
+	if err != nil {
+		return err
+	}
+	err = customModule.AddModelFromRegistry(ctx, encoder.API, revolutionpi.AbsoluteEncoderModel)
+	if err != nil {
+		return err
+	}
+	err = customModule.AddModelFromRegistry(ctx, sensor.API, revolutionpi.SensorModel)
+	if err != nil {
+		return err
+	}
+	err = customModule.AddModelFromRegistry(ctx, sensor.API, revolutionpi.StatusSensorModel)
+	if err != nil {
+		return err
+	}
+	err = customModule.AddModelFromRegistry(ctx, sensor.API, revolutionpi.TemperatureSensorModel)
+	if err != nil {
+		return err
+	}
 
 	err = customModule.Start(ctx)
 	defer customModule.Close(ctx)
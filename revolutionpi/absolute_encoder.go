@@ -0,0 +1,314 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/encoder"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+const (
+	codeGray   = "gray"
+	codeBinary = "binary"
+
+	absoluteStatusKey = "status"
+
+	// defaultAbsolutePollInterval is how often a multi-turn absolute encoder is sampled when no interval is configured.
+	defaultAbsolutePollInterval = 10 * time.Millisecond
+	// maxAbsoluteBits is the number of digital inputs of a DIO module.
+	maxAbsoluteBits = 16
+)
+
+// AbsoluteEncoderModel is the model triplet for the rev-pi parallel absolute encoder.
+var AbsoluteEncoderModel = resource.NewModel("viam-labs", "kunbus", "revolutionpi-absolute-encoder")
+
+// AbsoluteEncoderConfig is the config for the rev-pi parallel absolute encoder.
+type AbsoluteEncoderConfig struct {
+	// Board is the revolution pi board the encoder is connected to.
+	Board string `json:"board"`
+	// Pins are the digital inputs of the code, least significant bit first. They have to be on the same DIO module.
+	// Encoders usually have 10 to 16 bits, but fewer pins are allowed, since low resolution encoders are decoded
+	// the same way.
+	Pins []string `json:"pins"`
+	// Code is the code output by the encoder, "gray" (the default) or "binary".
+	Code string `json:"code,omitempty"`
+	// Offset is the decoded position, in ticks, that is reported as position 0.
+	Offset int `json:"offset,omitempty"`
+	// Reverse counts the position down when the code counts up.
+	Reverse bool `json:"reverse,omitempty"`
+	// MultiTurn counts full rotations by sampling the encoder in the background, instead of reporting the
+	// position within one rotation.
+	MultiTurn bool `json:"multi_turn,omitempty"`
+	// PollIntervalMs is how often a multi-turn encoder is sampled, 10 ms by default. The encoder has to turn
+	// less than half a rotation between two samples.
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+}
+
+func init() {
+	resource.RegisterComponent(
+		encoder.API,
+		AbsoluteEncoderModel,
+		resource.Registration[encoder.Encoder, *AbsoluteEncoderConfig]{Constructor: newAbsoluteEncoder})
+}
+
+// Validate validates the AbsoluteEncoderConfig.
+func (cfg *AbsoluteEncoderConfig) Validate(path string) ([]string, error) {
+	if cfg.Board == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "board")
+	}
+	if len(cfg.Pins) == 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "pins")
+	}
+	if len(cfg.Pins) > maxAbsoluteBits {
+		return nil, resource.NewConfigValidationError(path,
+			fmt.Errorf("an absolute encoder can use at most %d pins, got %d", maxAbsoluteBits, len(cfg.Pins)))
+	}
+	switch cfg.Code {
+	case "", codeGray, codeBinary:
+	default:
+		return nil, resource.NewConfigValidationError(path,
+			fmt.Errorf("unknown code %q, expected %q or %q", cfg.Code, codeGray, codeBinary))
+	}
+	if cfg.Offset < 0 || cfg.Offset >= 1<<len(cfg.Pins) {
+		return nil, resource.NewConfigValidationError(path,
+			fmt.Errorf("offset must be between 0 and %d for %d pins", 1<<len(cfg.Pins)-1, len(cfg.Pins)))
+	}
+	if cfg.PollIntervalMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("poll_interval_ms cannot be negative"))
+	}
+	return []string{cfg.Board}, nil
+}
+
+// grayToBinary decodes a Gray code value.
+func grayToBinary(gray uint16) uint16 {
+	value := gray
+	for shift := gray >> 1; shift != 0; shift >>= 1 {
+		value ^= shift
+	}
+	return value
+}
+
+// absoluteEncoder reads an absolute encoder whose code is wired in parallel to the digital inputs of a DIO module.
+type absoluteEncoder struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+	pins   []*gpioPin
+	chip   *gpioChip
+	gray   bool
+	// resolution is the number of positions in one rotation
+	resolution int64
+	reverse    bool
+	multiTurn  bool
+
+	mu     sync.Mutex
+	offset int64
+	// last is the last position within a rotation and turns the number of full rotations, for multi-turn encoders
+	last    int64
+	turns   int64
+	sampled bool
+
+	cancelCtx               context.Context
+	cancelFunc              func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+func newAbsoluteEncoder(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (encoder.Encoder, error) {
+	newConf, err := resource.NativeConfig[*AbsoluteEncoderConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	revPi, err := revolutionPiBoardFromDependencies(deps, newConf.Board)
+	if err != nil {
+		return nil, err
+	}
+	pins := make([]*gpioPin, 0, len(newConf.Pins))
+	for _, name := range newConf.Pins {
+		pin, err := revPi.digitalInputPin(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(pins) > 0 && pin.inputOffset != pins[0].inputOffset {
+			return nil, fmt.Errorf("absolute encoder pin %s is not on the same DIO module as %s", name, newConf.Pins[0])
+		}
+		pins = append(pins, pin)
+	}
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	enc := &absoluteEncoder{
+		Named:      conf.ResourceName().AsNamed(),
+		logger:     logger,
+		pins:       pins,
+		chip:       pins[0].ControlChip.acquire(),
+		gray:       newConf.Code != codeBinary,
+		resolution: 1 << len(pins),
+		reverse:    newConf.Reverse,
+		multiTurn:  newConf.MultiTurn,
+		offset:     int64(newConf.Offset),
+		cancelCtx:  cancelCtx,
+		cancelFunc: cancelFunc,
+	}
+	if _, err := enc.sample(); err != nil {
+		cancelFunc()
+		return nil, multierr.Combine(err, enc.chip.release())
+	}
+	if enc.multiTurn {
+		interval := defaultAbsolutePollInterval
+		if newConf.PollIntervalMs > 0 {
+			interval = time.Duration(newConf.PollIntervalMs) * time.Millisecond
+		}
+		enc.startPoller(interval)
+	}
+	return enc, nil
+}
+
+// readCode reads the code from the digital inputs as one word, and returns it with the bits in the configured order.
+func (enc *absoluteEncoder) readCode() (uint16, error) {
+	enc.chip.configMu.RLock()
+	defer enc.chip.configMu.RUnlock()
+	inputs := make([]byte, 2)
+	for _, pin := range enc.pins {
+		if !pin.initialized {
+			return 0, fmt.Errorf("absolute encoder pin %s is no longer part of the configuration", pin.Name)
+		}
+	}
+	if _, err := enc.chip.readAt(inputs, int64(enc.pins[0].inputOffset)); err != nil {
+		return 0, err
+	}
+	word := binary.LittleEndian.Uint16(inputs)
+	var code uint16
+	for i, pin := range enc.pins {
+		input := (pin.Address-pin.inputOffset)*8 + uint16(pin.BitPosition)
+		code |= (word >> input & 1) << i
+	}
+	return code, nil
+}
+
+// value returns the position within a rotation for a code, with the direction and offset applied.
+func (enc *absoluteEncoder) value(code uint16) int64 {
+	value := int64(code)
+	if enc.gray {
+		value = int64(grayToBinary(code))
+	}
+	if enc.reverse {
+		value = enc.resolution - 1 - value
+	}
+	return ((value-enc.offset)%enc.resolution + enc.resolution) % enc.resolution
+}
+
+// sample reads the encoder and returns the code. Multi-turn encoders count a full rotation whenever the
+// position moves by more than half a rotation between two samples. The code is read while holding mu, so a
+// reset cannot move the offset between the read and the comparison with the last position.
+func (enc *absoluteEncoder) sample() (uint16, error) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	code, err := enc.readCode()
+	if err != nil {
+		return 0, err
+	}
+	value := enc.value(code)
+	switch delta := value - enc.last; {
+	case !enc.sampled:
+	case delta > enc.resolution/2:
+		enc.turns--
+	case delta < -enc.resolution/2:
+		enc.turns++
+	}
+	enc.last = value
+	enc.sampled = true
+	return code, nil
+}
+
+// startPoller samples a multi-turn encoder from a background worker until the encoder closes.
+func (enc *absoluteEncoder) startPoller(interval time.Duration) {
+	enc.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for utils.SelectContextOrWait(enc.cancelCtx, interval) {
+			if _, err := enc.sample(); err != nil {
+				enc.logger.Debugf("failed to sample absolute encoder: %v", err)
+			}
+		}
+	}, enc.activeBackgroundWorkers.Done)
+}
+
+// position returns the position in ticks, within one rotation for single-turn encoders.
+func (enc *absoluteEncoder) position() int64 {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if !enc.multiTurn {
+		return enc.last
+	}
+	return enc.turns*enc.resolution + enc.last
+}
+
+func (enc *absoluteEncoder) Position(ctx context.Context, positionType encoder.PositionType,
+	extra map[string]interface{},
+) (float64, encoder.PositionType, error) {
+	if _, err := enc.sample(); err != nil {
+		return 0, encoder.PositionTypeTicks, err
+	}
+	return toPositionType(float64(enc.position()), float64(enc.resolution), positionType)
+}
+
+// ResetPosition makes the current position position 0, by moving the offset. The offset is not saved,
+// so the configured offset applies again after a restart.
+func (enc *absoluteEncoder) ResetPosition(ctx context.Context, extra map[string]interface{}) error {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	code, err := enc.readCode()
+	if err != nil {
+		return err
+	}
+	enc.offset = 0
+	enc.offset = enc.value(code)
+	enc.last = 0
+	enc.turns = 0
+	return nil
+}
+
+func (enc *absoluteEncoder) Properties(ctx context.Context, extra map[string]interface{}) (encoder.Properties, error) {
+	return encoder.Properties{TicksCountSupported: true, AngleDegreesSupported: true}, nil
+}
+
+// DoCommand supports {"status": true}, which returns the raw code, the resolution and whether the encoder
+// reports positions within a single rotation.
+func (enc *absoluteEncoder) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return runCommands(ctx, []boardCommand{{key: absoluteStatusKey, handler: enc.status}}, req)
+}
+
+func (enc *absoluteEncoder) status(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	code, err := enc.sample()
+	if err != nil {
+		return err
+	}
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	resp[absoluteStatusKey] = map[string]interface{}{
+		"code":        code,
+		"resolution":  enc.resolution,
+		"single_turn": !enc.multiTurn,
+		"offset":      enc.offset,
+	}
+	return nil
+}
+
+func (enc *absoluteEncoder) Close(ctx context.Context) error {
+	enc.cancelFunc()
+	enc.activeBackgroundWorkers.Wait()
+	return enc.chip.release()
+}
@@ -4,9 +4,9 @@
 package revolutionpi
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"go.viam.com/rdk/components/board"
@@ -14,6 +14,16 @@ import (
 
 const (
 	analogInputMemAddress = 24
+
+	// address offsets of the RTD inputs of AIO modules, relative to the input offset
+	rtdValueOffset  = 12 // RTDValue_1 and RTDValue_2, in 0.1 °C before scaling
+	rtdStatusOffset = 16 // RTDStatus_1 and RTDStatus_2
+	rtdConfigOffset = 53 // RTDType, RTDWiring, RTDMultiplier, RTDDivisor and RTDOffset of RTD input 1
+	rtdConfigLength = 8  // distance between the configuration of RTD input 1 and 2
+
+	// the measuring range of the RTD inputs, in 0.1 °C
+	rtdMinTemperature = -2000
+	rtdMaxTemperature = 8500
 )
 
 type analogPin struct {
@@ -24,6 +34,8 @@ type analogPin struct {
 	outputOffset uint16
 	inputOffset  uint16
 	info         analogInfo
+	rtd          rtdInfo // only set for RTD inputs
+	initialized  bool
 }
 
 type analogInfo struct {
@@ -32,6 +44,26 @@ type analogInfo struct {
 	isCurrent bool
 }
 
+// rtdInfo is the configuration of an RTD input. The module reports
+// RTDValue = temperature in 0.1 °C * multiplier / divisor + offset.
+type rtdInfo struct {
+	sensorType string // "PT100" or "PT1000"
+	wires      int    // 2, 3 or 4 wire connection
+	multiplier int16
+	divisor    int16
+	offset     int16
+}
+
+// celsius converts a value reported by the module to °C.
+func (rtd rtdInfo) celsius(value int16) float64 {
+	return float64(int(value)-int(rtd.offset)) * float64(rtd.divisor) / float64(rtd.multiplier) / 10
+}
+
+// raw converts a temperature in 0.1 °C to the value reported by the module.
+func (rtd rtdInfo) raw(deciCelsius int) int {
+	return deciCelsius*int(rtd.multiplier)/int(rtd.divisor) + int(rtd.offset)
+}
+
 func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 	analogPin := analogPin{Name: str32(pin.strVarName), Address: pin.i16uAddress, Length: pin.i16uLength, ControlChip: g}
 	aio, err := findDevice(analogPin.Address, g.aioDevices)
@@ -48,9 +80,9 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 		analogInputNumber := (analogPin.Address - analogPin.inputOffset) / 2                     // results in 0, 1, 2, or 3
 		inputRangeAddress := analogInputNumber*7 + analogInputMemAddress + analogPin.inputOffset // results in pin 24, 31, 38, or 45
 		bufInputRange := make([]byte, 1)
-		n, err := analogPin.ControlChip.fileHandle.ReadAt(bufInputRange, int64(inputRangeAddress))
+		n, err := analogPin.ControlChip.readAt(bufInputRange, int64(inputRangeAddress))
 		if err != nil {
-			return nil, fmt.Errorf("failed to read input range for analog pin %s", analogPin.Name)
+			return nil, fmt.Errorf("failed to read input range for analog pin %s: %w", analogPin.Name, err)
 		}
 		if n != 1 {
 			return nil, fmt.Errorf("expected 1 byte, got %#v", bufInputRange)
@@ -59,6 +91,15 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 		if err != nil {
 			return nil, err
 		}
+	} else if analogPin.isRTDInput() {
+		analogPin.rtd, err = analogPin.readRTDInfo()
+		if err != nil {
+			return nil, err
+		}
+		analogPin.info = analogInfo{
+			min: min(analogPin.rtd.raw(rtdMinTemperature), analogPin.rtd.raw(rtdMaxTemperature)),
+			max: max(analogPin.rtd.raw(rtdMinTemperature), analogPin.rtd.raw(rtdMaxTemperature)),
+		}
 	} else if analogPin.isAnalogOutput() {
 		// check to see if analog output is enabled
 		outputRangeAddress := analogPin.inputOffset + 69
@@ -67,7 +108,7 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 			outputRangeAddress = analogPin.inputOffset + 79
 		}
 		bufOutputRange := make([]byte, 1)
-		n, err := analogPin.ControlChip.fileHandle.ReadAt(bufOutputRange, int64(outputRangeAddress))
+		n, err := analogPin.ControlChip.readAt(bufOutputRange, int64(outputRangeAddress))
 		if err != nil {
 			return nil, err
 		}
@@ -80,23 +121,39 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 			return nil, err
 		}
 	}
+	analogPin.initialized = true
 	return &analogPin, nil
 }
 
 func (pin *analogPin) Read(ctx context.Context, extra map[string]interface{}) (board.AnalogValue, error) {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
+	if !pin.initialized {
+		return board.AnalogValue{}, errors.New("pin not initialized")
+	}
+	if pin.isRTDInput() {
+		value, _, err := pin.readRTD()
+		if err != nil {
+			return board.AnalogValue{}, err
+		}
+		// step size converts the value to °C, but only (value - RTDOffset) * step size is the temperature when
+		// PiCtory configures an offset. Min and max are raw values, so they include the offset.
+		stepSize := float32(pin.rtd.divisor) / float32(pin.rtd.multiplier) / 10
+		return board.AnalogValue{Value: int(value), Min: float32(pin.info.min), Max: float32(pin.info.max), StepSize: stepSize}, nil
+	}
 	if !pin.isAnalogInput() {
 		return board.AnalogValue{}, fmt.Errorf("cannot ReadAnalog, pin %s is not an analog input pin", pin.Name)
 	}
 	pin.ControlChip.logger.Debugf("Reading from %v, length: %v byte(s)", pin.Address, pin.Length/8)
 	b := make([]byte, pin.Length/8)
-	n, err := pin.ControlChip.fileHandle.ReadAt(b, int64(pin.Address))
+	n, err := pin.ControlChip.readAt(b, int64(pin.Address))
 	pin.ControlChip.logger.Debugf("Read %#v bytes", b)
-	if n != 2 {
-		return board.AnalogValue{}, fmt.Errorf("expected 2 bytes, got %#v", b)
-	}
 	if err != nil {
 		return board.AnalogValue{}, err
 	}
+	if n != 2 {
+		return board.AnalogValue{}, fmt.Errorf("expected 2 bytes, got %#v", b)
+	}
 	val := binary.LittleEndian.Uint16(b)
 	// NOTE: we currently assume that the input multiplier, divisor, and offset have not been modified
 	// the min and max values will change if a user modifies these.
@@ -111,7 +168,15 @@ func (pin *analogPin) Close(ctx context.Context) error {
 }
 
 func (pin *analogPin) Write(ctx context.Context, value int, extra map[string]interface{}) error {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	pin.ControlChip.logger.Debugf("Analog: %#v", pin)
+	if !pin.initialized {
+		return errors.New("pin not initialized")
+	}
+	if pin.isAnalogInput() || pin.isRTDInput() {
+		return pin.writeSimulatedInput(value)
+	}
 	if !pin.isAnalogOutput() {
 		return fmt.Errorf("cannot Write to Analog, pin %s is not an analog output pin", pin.Name)
 	}
@@ -122,12 +187,24 @@ func (pin *analogPin) Write(ctx context.Context, value int, extra map[string]int
 		return fmt.Errorf("value of %v is not within expected range (%v to %v)", value, pin.info.min, pin.info.max)
 	}
 
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, int32(value))
-	if err != nil {
-		return err
+	// OutputValue_1 and OutputValue_2 are 16 bit values next to each other, so only write 2 bytes
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(int16(value)))
+	return pin.ControlChip.writeValue(int64(pin.Address), b)
+}
+
+// writeSimulatedInput writes a value to an analog input while inputs are being simulated.
+func (pin *analogPin) writeSimulatedInput(value int) error {
+	if err := pin.ControlChip.checkInputSimulation(pin.Name); err != nil {
+		return fmt.Errorf("cannot Write to Analog: %w", err)
+	}
+	if value > pin.info.max || value < pin.info.min {
+		return fmt.Errorf("value of %v is not within expected range (%v to %v)", value, pin.info.min, pin.info.max)
 	}
-	return pin.ControlChip.writeValue(int64(pin.Address), buf.Bytes())
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(int16(value)))
+	// inputs are never exported, they go straight to the process image
+	return pin.ControlChip.writeImage(int64(pin.Address), b)
 }
 
 // Analog output pins are located at address 0 or 2 + outputOffset.
@@ -140,6 +217,59 @@ func (pin *analogPin) isAnalogInput() bool {
 	return pin.Address >= pin.inputOffset && pin.Address < pin.inputOffset+8
 }
 
+// RTD input pins are located at address 12 or 14 + inputOffset.
+func (pin *analogPin) isRTDInput() bool {
+	return pin.Address == pin.inputOffset+rtdValueOffset || pin.Address == pin.inputOffset+rtdValueOffset+2
+}
+
+// rtdIndex returns 0 for RTDValue_1 and 1 for RTDValue_2.
+func (pin *analogPin) rtdIndex() uint16 {
+	return (pin.Address - pin.inputOffset - rtdValueOffset) / 2
+}
+
+// readRTDInfo reads the type, wiring and scaling of an RTD input from the module configuration.
+func (pin *analogPin) readRTDInfo() (rtdInfo, error) {
+	config := make([]byte, rtdConfigLength)
+	address := int64(pin.inputOffset + rtdConfigOffset + pin.rtdIndex()*rtdConfigLength)
+	if _, err := pin.ControlChip.readAt(config, address); err != nil {
+		return rtdInfo{}, fmt.Errorf("failed to read the configuration of RTD input %s: %w", pin.Name, err)
+	}
+	rtd := rtdInfo{
+		multiplier: int16(binary.LittleEndian.Uint16(config[2:])),
+		divisor:    int16(binary.LittleEndian.Uint16(config[4:])),
+		offset:     int16(binary.LittleEndian.Uint16(config[6:])),
+	}
+	switch config[0] {
+	case 0:
+		rtd.sensorType = "PT100"
+	case 1:
+		rtd.sensorType = "PT1000"
+	default:
+		return rtdInfo{}, fmt.Errorf("invalid RTD type for %s, got %v", pin.Name, config[0])
+	}
+	switch config[1] {
+	case 0, 1, 2:
+		rtd.wires = int(config[1]) + 2
+	default:
+		return rtdInfo{}, fmt.Errorf("invalid RTD wiring for %s, got %v", pin.Name, config[1])
+	}
+	if rtd.multiplier == 0 || rtd.divisor == 0 {
+		return rtdInfo{}, fmt.Errorf("RTD input %s has a multiplier or divisor of 0", pin.Name)
+	}
+	return rtd, nil
+}
+
+// readRTD reads the value and the status of an RTD input from the same cycle. The caller holds configMu for reading.
+func (pin *analogPin) readRTD() (int16, byte, error) {
+	inputs := make([]byte, rtdStatusOffset+2-rtdValueOffset)
+	if _, err := pin.ControlChip.readAt(inputs, int64(pin.inputOffset+rtdValueOffset)); err != nil {
+		return 0, 0, err
+	}
+	index := pin.rtdIndex()
+	value := int16(binary.LittleEndian.Uint16(inputs[2*index:]))
+	return value, inputs[rtdStatusOffset-rtdValueOffset+index], nil
+}
+
 func getAnalogOutputRange(val byte, name string) (analogInfo, error) {
 	switch val {
 	case 0:
@@ -0,0 +1,330 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+)
+
+const (
+	calibrateStartKey      = "calibrateStart"
+	calibratePointKey      = "calibratePoint"
+	calibrateCommitKey     = "calibrateCommit"
+	calibrationRecordsKey  = "calibrationRecords"
+	defaultCalibrationFile = "aio_calibration.json"
+)
+
+// aioCalibrateMode is the calibration step requested with kbAIOCalibrate, the i8uCallibrationMode field of
+// SAIOCalibrate in piControl.h. The values and the channel bitfield of calibrationChannel have not been checked
+// against a released piControl.h, and a commit writes to the flash of the module, so the calibration commands
+// are refused unless enable_aio_calibration is set.
+type aioCalibrateMode uint8
+
+const (
+	// aioCalibrateStart discards the current calibration of the channels and starts collecting reference points.
+	aioCalibrateStart aioCalibrateMode = 0
+	// aioCalibratePoint adds a reference point to the calibration.
+	aioCalibratePoint aioCalibrateMode = 1
+	// aioCalibrateCommit stores the calibration in the module.
+	aioCalibrateCommit aioCalibrateMode = 2
+)
+
+// calibrationPoint is a value measured by the module and the value it should have been.
+type calibrationPoint struct {
+	Measured  int16 `json:"measured"`
+	Reference int16 `json:"reference"`
+}
+
+// calibrationSession is a calibration of a single channel that has been started but not committed.
+type calibrationSession struct {
+	address uint8
+	channel uint8
+	points  []calibrationPoint
+}
+
+// calibrationRecord is the result of the last committed calibration of a channel. piControl cannot read the
+// calibration back from the module, so the gain and offset are estimated from the reference points by the board
+// and may differ from what the module computed.
+type calibrationRecord struct {
+	Address         uint8              `json:"address"`
+	Channels        uint8              `json:"channels"` // the kbAIOCalibrate channel bitfield
+	CalibratedAt    time.Time          `json:"calibrated_at"`
+	EstimatedGain   float64            `json:"estimated_gain"`
+	EstimatedOffset float64            `json:"estimated_offset"`
+	Points          []calibrationPoint `json:"points"`
+}
+
+func (r calibrationRecord) toMap() map[string]interface{} {
+	points := make([]interface{}, 0, len(r.Points))
+	for _, p := range r.Points {
+		points = append(points, map[string]interface{}{"measured": p.Measured, "reference": p.Reference})
+	}
+	return map[string]interface{}{
+		"address":          r.Address,
+		"channels":         r.Channels,
+		"calibrated_at":    r.CalibratedAt.Format(time.RFC3339),
+		"estimated_gain":   r.EstimatedGain,
+		"estimated_offset": r.EstimatedOffset,
+		"points":           points,
+	}
+}
+
+// calibrationChannel returns the module address and the kbAIOCalibrate channel bit of an analog input or output.
+func (g *gpioChip) calibrationChannel(pinName string) (uint8, uint8, error) {
+	pin, err := g.GetAnalogPin(pinName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("pin %s is not an AIO channel: %w", pinName, err)
+	}
+	aio, err := findDevice(pin.Address, g.aioDevices)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case pin.isAnalogInput():
+		return aio.i8uAddress, uint8(1 << ((pin.Address - pin.inputOffset) / 2)), nil
+	case pin.isAnalogOutput():
+		return aio.i8uAddress, uint8(1 << (4 + (pin.Address-pin.outputOffset)/2)), nil
+	default:
+		return 0, 0, fmt.Errorf("pin %s is not an analog input or output", pinName)
+	}
+}
+
+// aioCalibrate sends a calibration step for the given channels of an AIO module.
+func (g *gpioChip) aioCalibrate(address, channels uint8, mode aioCalibrateMode, point calibrationPoint) error {
+	request := SAIOCalibrate{
+		i8uAddress:  address,
+		i8uMode:     uint8(mode),
+		i8uChannels: channels,
+		i16sXVal:    point.Measured,
+		i16sYVal:    point.Reference,
+	}
+	//nolint:gosec
+	err := g.ioCtl(uintptr(kbAIOCalibrate), unsafe.Pointer(&request))
+	if err != nil {
+		return fmt.Errorf("failed to calibrate module %d on %v: %w", address, g.dev, err)
+	}
+	return nil
+}
+
+// fitCalibration estimates the gain and offset that map the measured values onto the reference values
+// with the least squared error. A single point only corrects the offset.
+func fitCalibration(points []calibrationPoint) (float64, float64, error) {
+	if len(points) == 0 {
+		return 0, 0, errors.New("at least one reference point is required")
+	}
+	if len(points) == 1 {
+		return 1, float64(points[0].Reference) - float64(points[0].Measured), nil
+	}
+	var sumX, sumY, sumXX, sumXY float64
+	for _, p := range points {
+		x, y := float64(p.Measured), float64(p.Reference)
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if math.Abs(denominator) < 1e-9 {
+		return 0, 0, errors.New("reference points must have different measured values")
+	}
+	gain := (n*sumXY - sumX*sumY) / denominator
+	offset := (sumY - gain*sumX) / n
+	return gain, offset, nil
+}
+
+// calibrationFilePath returns the file calibration records are persisted to, or "" if there is none.
+func calibrationFilePath(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if dataDir := os.Getenv("VIAM_MODULE_DATA"); dataDir != "" {
+		return filepath.Join(dataDir, defaultCalibrationFile)
+	}
+	return ""
+}
+
+// loadCalibrationRecords reads the calibration records persisted at path. A missing file has no records.
+func loadCalibrationRecords(path string) (map[string]calibrationRecord, error) {
+	records := map[string]calibrationRecord{}
+	if path == "" {
+		return records, nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calibration records: %w", err)
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse calibration records %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// saveCalibrationRecords writes the calibration records to path, replacing the file so a crash never leaves it half written.
+func saveCalibrationRecords(path string, records map[string]calibrationRecord) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write calibration records: %w", err)
+	}
+	return nil
+}
+
+// checkCalibrationEnabled returns an error for the calibration command key unless enable_aio_calibration is set.
+func (b *revolutionPiBoard) checkCalibrationEnabled(key string) error {
+	if !b.calibrationEnabled {
+		return fmt.Errorf("error performing %s: AIO calibration is disabled, set enable_aio_calibration to use it", key)
+	}
+	return nil
+}
+
+// calibrateStart starts calibrating an analog input or output, discarding its current calibration.
+func (b *revolutionPiBoard) calibrateStart(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	if err := b.checkCalibrationEnabled(calibrateStartKey); err != nil {
+		return err
+	}
+	pinName, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("error performing %s: expected string got %v", calibrateStartKey, value)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+	address, channel, err := b.controlChip.calibrationChannel(pinName)
+	if err != nil {
+		return err
+	}
+	if err := b.controlChip.aioCalibrate(address, channel, aioCalibrateStart, calibrationPoint{}); err != nil {
+		return err
+	}
+	b.calibrations[pinName] = &calibrationSession{address: address, channel: channel}
+	resp[calibrateStartKey] = pinName
+	return nil
+}
+
+// calibratePoint applies a reference point to a calibration started with calibrateStart.
+func (b *revolutionPiBoard) calibratePoint(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	if err := b.checkCalibrationEnabled(calibratePointKey); err != nil {
+		return err
+	}
+	args, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("error performing %s: expected an object with channel, measured and reference got %v",
+			calibratePointKey, value)
+	}
+	pinName, ok := args["channel"].(string)
+	if !ok {
+		return fmt.Errorf("error performing %s: expected string channel got %v", calibratePointKey, args["channel"])
+	}
+	measured, err := calibrationValue(args, "measured")
+	if err != nil {
+		return err
+	}
+	reference, err := calibrationValue(args, "reference")
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	session, ok := b.calibrations[pinName]
+	if !ok {
+		return fmt.Errorf("calibration of %s was not started, use %s first", pinName, calibrateStartKey)
+	}
+	point := calibrationPoint{Measured: measured, Reference: reference}
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+	if err := b.controlChip.aioCalibrate(session.address, session.channel, aioCalibratePoint, point); err != nil {
+		return err
+	}
+	session.points = append(session.points, point)
+	resp[calibratePointKey] = len(session.points)
+	return nil
+}
+
+// calibrationValue reads a reference point value from the arguments of calibratePoint.
+func calibrationValue(args map[string]interface{}, key string) (int16, error) {
+	value, ok := args[key].(float64)
+	if !ok {
+		return 0, fmt.Errorf("error performing %s: expected number %s got %v", calibratePointKey, key, args[key])
+	}
+	if value < math.MinInt16 || value > math.MaxInt16 {
+		return 0, fmt.Errorf("error performing %s: %s %v is out of range", calibratePointKey, key, value)
+	}
+	return int16(value), nil
+}
+
+// calibrateCommit stores the calibration in the module, reports the estimated gain and offset and records when
+// the channel was calibrated.
+func (b *revolutionPiBoard) calibrateCommit(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	if err := b.checkCalibrationEnabled(calibrateCommitKey); err != nil {
+		return err
+	}
+	pinName, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("error performing %s: expected string got %v", calibrateCommitKey, value)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	session, ok := b.calibrations[pinName]
+	if !ok {
+		return fmt.Errorf("calibration of %s was not started, use %s first", pinName, calibrateStartKey)
+	}
+	gain, offset, err := fitCalibration(session.points)
+	if err != nil {
+		return fmt.Errorf("cannot commit calibration of %s: %w", pinName, err)
+	}
+	b.controlChip.configMu.RLock()
+	err = b.controlChip.aioCalibrate(session.address, session.channel, aioCalibrateCommit, calibrationPoint{})
+	b.controlChip.configMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	delete(b.calibrations, pinName)
+
+	record := calibrationRecord{
+		Address:         session.address,
+		Channels:        session.channel,
+		CalibratedAt:    time.Now(),
+		EstimatedGain:   gain,
+		EstimatedOffset: offset,
+		Points:          session.points,
+	}
+	b.calibrationRecords[pinName] = record
+	if err := saveCalibrationRecords(b.calibrationFile, b.calibrationRecords); err != nil {
+		// the module is already calibrated, so only the record is lost
+		b.logger.Errorf("calibration of %s was committed but not recorded: %v", pinName, err)
+	}
+	resp[calibrateCommitKey] = record.toMap()
+	return nil
+}
+
+// listCalibrationRecords returns when each channel was last calibrated and the resulting calibration.
+func (b *revolutionPiBoard) listCalibrationRecords(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	records := map[string]interface{}{}
+	for name, record := range b.calibrationRecords {
+		records[name] = record.toMap()
+	}
+	resp[calibrationRecordsKey] = records
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestFitCalibration(t *testing.T) {
+	gain, offset, err := fitCalibration([]calibrationPoint{{Measured: 100, Reference: 110}})
+	if err != nil || gain != 1 || offset != 10 {
+		t.Errorf("single point fit returned %v, %v, %v, expected 1, 10", gain, offset, err)
+	}
+	gain, offset, err = fitCalibration([]calibrationPoint{{Measured: 0, Reference: 5}, {Measured: 1000, Reference: 2005}})
+	if err != nil || math.Abs(gain-2) > 1e-9 || math.Abs(offset-5) > 1e-9 {
+		t.Errorf("two point fit returned %v, %v, %v, expected 2, 5", gain, offset, err)
+	}
+	if _, _, err := fitCalibration([]calibrationPoint{{Measured: 1, Reference: 2}, {Measured: 1, Reference: 3}}); err == nil {
+		t.Error("expected an error for points with the same measured value")
+	}
+}
+
+func TestSimulatedCalibration(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{EnableAIOCalibration: true})
+	if _, err := b.DoCommand(ctx, map[string]interface{}{calibrateCommitKey: "InputValue_1"}); err == nil {
+		t.Error("expected an error committing a calibration that was not started")
+	}
+	if _, err := b.DoCommand(ctx, map[string]interface{}{calibrateStartKey: "InputValue_2"}); err != nil {
+		t.Fatal(err)
+	}
+	point := map[string]interface{}{"channel": "InputValue_2", "measured": 9870.0, "reference": 10000.0}
+	if _, err := b.DoCommand(ctx, map[string]interface{}{calibratePointKey: point}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := b.DoCommand(ctx, map[string]interface{}{calibrateCommitKey: "InputValue_2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := resp[calibrateCommitKey].(map[string]interface{})
+	if record["channels"] != uint8(1<<1) || record["estimated_offset"] != 130.0 {
+		t.Errorf("unexpected calibration record %v", record)
+	}
+}
+
+func TestCalibrationDisabledByDefault(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+	for _, key := range []string{calibrateStartKey, calibrateCommitKey} {
+		if _, err := b.DoCommand(ctx, map[string]interface{}{key: "InputValue_1"}); err == nil {
+			t.Errorf("expected %s to be refused without enable_aio_calibration", key)
+		}
+	}
+	point := map[string]interface{}{"channel": "InputValue_1", "measured": 1.0, "reference": 2.0}
+	if _, err := b.DoCommand(ctx, map[string]interface{}{calibratePointKey: point}); err == nil {
+		t.Errorf("expected %s to be refused without enable_aio_calibration", calibratePointKey)
+	}
+	if _, err := b.DoCommand(ctx, map[string]interface{}{calibrationRecordsKey: true}); err != nil {
+		t.Errorf("reading the calibration records failed: %v", err)
+	}
+}
@@ -4,6 +4,9 @@
 package revolutionpi
 
 import (
+	"errors"
+	"fmt"
+
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/utils"
 )
@@ -13,6 +16,70 @@ var Model = resource.NewModel("viam-labs", "kunbus", "revolutionpi")
 
 // Config is the config for the rev-pi board.
 type Config struct {
-	resource.TriviallyValidateConfig
 	Attributes utils.AttributeMap `json:"attributes,omitempty"`
+	// Backend selects the process image, either "picontrol" (the default) or "simulated".
+	Backend string `json:"backend,omitempty"`
+	// PiCtoryConfig is the path to a PiCtory config.rsc file used to build the simulated process image.
+	PiCtoryConfig string `json:"pictory_config,omitempty"`
+	// OutputWatchdogMs arms the piControl output watchdog, which sets all outputs to 0
+	// if the module stops refreshing it for this many milliseconds. 0 disables the watchdog.
+	OutputWatchdogMs int `json:"output_watchdog_ms,omitempty"`
+	// SimulateInputs allows GPIO pins and analog writers to write to inputs while IO communication is stopped.
+	SimulateInputs bool `json:"simulate_inputs,omitempty"`
+	// CalibrationFile is where the board records when each AIO channel was last calibrated.
+	// Defaults to a file in the module's data directory.
+	CalibrationFile string `json:"calibration_file,omitempty"`
+	// EnableAIOCalibration allows the AIO calibration DoCommands, which write the calibration to the module.
+	EnableAIOCalibration bool `json:"enable_aio_calibration,omitempty"`
+	// ExportedOutputs enables the exported outputs mode for the listed output variables. Outputs are written to an
+	// application image that is pushed with kbSetExportedOutputs, so a PLC runtime can drive the remaining outputs.
+	ExportedOutputs []string `json:"exported_outputs,omitempty"`
+	// ExportedOutputsCycleMs is how often the exported outputs are pushed, 10 ms by default.
+	ExportedOutputsCycleMs int `json:"exported_outputs_cycle_ms,omitempty"`
+	// ReadCycleMs enables cached reads. The process image is read once every ReadCycleMs milliseconds
+	// and every pin reads from that snapshot. 0 reads the process image on every request.
+	ReadCycleMs int `json:"read_cycle_ms,omitempty"`
+	// TickPollIntervalMs is how often StreamTicks polls the counters of the digital interrupts, 5 ms by default.
+	TickPollIntervalMs int `json:"tick_poll_interval_ms,omitempty"`
+	// SoftwareInterrupts are digital inputs that are used as digital interrupts without counter mode in PiCtory.
+	SoftwareInterrupts []SoftwareInterruptConfig `json:"software_interrupts,omitempty"`
+	// SoftwareInterruptPollMs is how often the software interrupts sample their inputs, 1 ms by default.
+	SoftwareInterruptPollMs int `json:"software_interrupt_poll_ms,omitempty"`
+}
+
+// Validate validates the Config.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if err := validateBackend(cfg.Backend); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	if cfg.OutputWatchdogMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("output_watchdog_ms cannot be negative"))
+	}
+	if cfg.ExportedOutputsCycleMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("exported_outputs_cycle_ms cannot be negative"))
+	}
+	if cfg.ReadCycleMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("read_cycle_ms cannot be negative"))
+	}
+	if cfg.TickPollIntervalMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("tick_poll_interval_ms cannot be negative"))
+	}
+	if cfg.SoftwareInterruptPollMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("software_interrupt_poll_ms cannot be negative"))
+	}
+	for i := range cfg.SoftwareInterrupts {
+		if err := cfg.SoftwareInterrupts[i].Validate(fmt.Sprintf("%s.software_interrupts.%d", path, i)); err != nil {
+			return nil, resource.NewConfigValidationError(path, err)
+		}
+	}
+	return []string{}, nil
+}
+
+func validateBackend(backend string) error {
+	switch backend {
+	case "", backendPiControl, backendSimulated:
+		return nil
+	default:
+		return fmt.Errorf("unknown backend %q, expected %q or %q", backend, backendPiControl, backendSimulated)
+	}
 }
@@ -0,0 +1,66 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+)
+
+const listDevicesKey = "listDevices"
+
+// toMap describes the device for DoCommand responses.
+func (dev *SDeviceInfo) toMap() map[string]interface{} {
+	// piControl flags configured modules that are missing in the module type
+	moduleType := dev.i16uModuleType &^ piControlNotConnected
+	return map[string]interface{}{
+		"address":         dev.i8uAddress,
+		"moduleName":      getModuleName(moduleType),
+		"moduleType":      moduleType,
+		"connected":       dev.i16uModuleType&piControlNotConnected == 0,
+		"serialNumber":    dev.i32uSerialnumber,
+		"hwRevision":      dev.i16uHWRevision,
+		"firmwareVersion": fmt.Sprintf("%d.%d", dev.i16uSWMajor, dev.i16uSWMinor),
+		"svnRevision":     dev.i32uSVNRevision,
+		"baseOffset":      dev.i16uBaseOffset,
+		"inputOffset":     dev.i16uInputOffset,
+		"inputLength":     dev.i16uInputLength,
+		"outputOffset":    dev.i16uOutputOffset,
+		"outputLength":    dev.i16uOutputLength,
+		"configOffset":    dev.i16uConfigOffset,
+		"configLength":    dev.i16uConfigLength,
+		"active":          dev.i8uActive != 0,
+		"moduleState":     dev.i8uModuleState,
+	}
+}
+
+// listDevices returns every device known to piControl, or only the device at the given address.
+func (b *revolutionPiBoard) listDevices(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+
+	// JSON numbers arrive as float64
+	if address, ok := value.(float64); ok {
+		if address < 0 || address > 255 || address != float64(uint8(address)) {
+			return fmt.Errorf("error performing %s: invalid device address %v", listDevicesKey, value)
+		}
+		dev, err := b.controlChip.deviceInfo(uint8(address))
+		if err != nil {
+			return err
+		}
+		resp[listDevicesKey] = []interface{}{dev.toMap()}
+		return nil
+	}
+
+	deviceInfoList, err := b.controlChip.deviceInfoList()
+	if err != nil {
+		return err
+	}
+	devices := make([]interface{}, 0, len(deviceInfoList))
+	for i := range deviceInfoList {
+		devices = append(devices, deviceInfoList[i].toMap())
+	}
+	resp[listDevicesKey] = devices
+	return nil
+}
@@ -8,10 +8,13 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"unsafe"
 )
 
 const (
 	inputModeOffset = 88
+
+	inputModeCounterFalling = 2 // InputMode value of a counter that counts falling edges
 )
 
 // counterPin is the struct used for configuring an interrupt or encoder.
@@ -30,6 +33,9 @@ type counterPin struct {
 	inputOffset      uint16
 	enabled          bool
 	interruptAddress uint16
+	moduleAddress    uint8  // address of the DIO module in the current configuration
+	counterIndex     uint16 // 0-15 index of the input the counter belongs to
+	inputMode        byte   // 1 counts rising edges, 2 counts falling edges and 3 is an encoder
 }
 
 // diWrapper wraps a digital interrupt pin with the DigitalInterrupt interface.
@@ -50,6 +56,7 @@ func initializeDigitalInterrupt(pin SPIVariable, g *gpioChip, isEncoder bool) (*
 	// store the input & output offsets of the board for quick reference
 	di.outputOffset = dio.i16uOutputOffset
 	di.inputOffset = dio.i16uInputOffset
+	di.moduleAddress = dio.i8uAddress
 
 	var addressInputMode uint16
 
@@ -73,7 +80,7 @@ func initializeDigitalInterrupt(pin SPIVariable, g *gpioChip, isEncoder bool) (*
 
 	b := make([]byte, 1)
 	// read from the input mode addresses to see if the pin is configured for interrupts
-	n, err := di.controlChip.fileHandle.ReadAt(b, int64(di.inputOffset+inputModeOffset+addressInputMode))
+	n, err := di.controlChip.readAt(b, int64(di.inputOffset+inputModeOffset+addressInputMode))
 	if err != nil {
 		return &counterPin{}, err
 	}
@@ -91,6 +98,8 @@ func initializeDigitalInterrupt(pin SPIVariable, g *gpioChip, isEncoder bool) (*
 	}
 
 	di.enabled = true
+	di.counterIndex = addressInputMode
+	di.inputMode = b[0]
 
 	return &di, nil
 }
@@ -105,12 +114,14 @@ func (di *diWrapper) Value(ctx context.Context, extra map[string]interface{}) (i
 
 // Note: The revolution pi only supports uint32 counters, while the Value API expects int64.
 func (di *counterPin) Value() (uint32, error) {
+	di.controlChip.configMu.RLock()
+	defer di.controlChip.configMu.RUnlock()
 	if !di.enabled {
 		return 0, fmt.Errorf("cannot get digital interrupt value, pin %s is not configured as an interrupt", di.pinName)
 	}
 	di.controlChip.logger.Debugf("Reading from %d, length: 4 byte(s)", di.interruptAddress)
 	b := make([]byte, 4)
-	n, err := di.controlChip.fileHandle.ReadAt(b, int64(di.interruptAddress))
+	n, err := di.controlChip.readAt(b, int64(di.interruptAddress))
 	if err != nil {
 		return 0, err
 	}
@@ -122,6 +133,71 @@ func (di *counterPin) Value() (uint32, error) {
 	return val, nil
 }
 
+// reset sets the counter back to 0 on the DIO module.
+func (di *counterPin) reset() error {
+	di.controlChip.configMu.RLock()
+	defer di.controlChip.configMu.RUnlock()
+	return di.resetLocked()
+}
+
+// resetLocked is reset for callers that already hold the configuration lock.
+func (di *counterPin) resetLocked() error {
+	if !di.enabled {
+		return fmt.Errorf("cannot reset counter, pin %s is not configured as an interrupt", di.pinName)
+	}
+	command := SDIOResetCounter{i8uAddress: di.moduleAddress, i16uBitfield: 1 << di.counterIndex}
+	di.controlChip.logger.Debugf("Command: %#v", command)
+	//nolint:gosec
+	err := di.controlChip.ioCtl(uintptr(kbDIOResetCounter), unsafe.Pointer(&command))
+	if err != nil {
+		return fmt.Errorf("failed to reset counter %s: %w", di.pinName, err)
+	}
+	di.controlChip.noteCounterReset(di.interruptAddress)
+	return nil
+}
+
+// counterResets is the number of resets of each counter by address, and the number of piControl resets,
+// which may restart every counter.
+type counterResets struct {
+	counters map[uint16]uint64
+	driver   uint64
+}
+
+// counterResetCount is the number of resets of one counter and of piControl.
+type counterResetCount struct {
+	counter uint64
+	driver  uint64
+}
+
+// noteCounterReset records a reset of the counter at address.
+func (g *gpioChip) noteCounterReset(address uint16) {
+	g.resetsMu.Lock()
+	defer g.resetsMu.Unlock()
+	if g.resets.counters == nil {
+		g.resets.counters = map[uint16]uint64{}
+	}
+	g.resets.counters[address]++
+}
+
+// noteDriverReset records a reset of piControl.
+func (g *gpioChip) noteDriverReset() {
+	g.resetsMu.Lock()
+	defer g.resetsMu.Unlock()
+	g.resets.driver++
+}
+
+// resetCount returns the number of resets of the counter and of piControl, so a change shows that the counter
+// may have started over.
+func (di *counterPin) resetCount() counterResetCount {
+	di.controlChip.configMu.RLock()
+	address := di.interruptAddress
+	di.controlChip.configMu.RUnlock()
+	g := di.controlChip
+	g.resetsMu.Lock()
+	defer g.resetsMu.Unlock()
+	return counterResetCount{counter: g.resets.counters[address], driver: g.resets.driver}
+}
+
 func (di *diWrapper) Name() string {
 	return di.pin.pinName
 }
@@ -5,14 +5,14 @@ package revolutionpi
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
-	"sync/atomic"
+	"sync"
+	"time"
 
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/board"
 	"go.viam.com/rdk/components/encoder"
-	"go.viam.com/rdk/grpc"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/utils"
@@ -22,8 +22,26 @@ import (
 type revolutionPiEncoder struct {
 	resource.Named
 	resource.AlwaysRebuild
-	pin     *counterPin
-	zeroPos atomic.Int32
+	logger logging.Logger
+	pin    *counterPin
+	// chip is the board's chip, shared with the board, or the encoder's own chip if no board is configured
+	chip *gpioChip
+	// mu keeps a sample from being taken while the counter is reset, and guards samples and resets
+	mu       sync.Mutex
+	position positionAccumulator
+	// resets is the number of resets of the counter and of piControl the encoder has accounted for
+	resets counterResetCount
+	// samples are the positions within the velocity window, oldest first
+	samples        []positionSample
+	velocityWindow time.Duration
+	// positionFile persists the position across restarts, empty if the position is not persisted
+	positionFile string
+	// ticksPerRotation is the number of counts per rotation, 0 if it is not known
+	ticksPerRotation float64
+
+	cancelCtx               context.Context
+	cancelFunc              func()
+	activeBackgroundWorkers sync.WaitGroup
 }
 
 // EncoderModel is the model triplet for the rev-pi board encoder.
@@ -32,6 +50,20 @@ var EncoderModel = resource.NewModel("viam-labs", "kunbus", "revolutionpi-encode
 // EncoderConfig is the config for the rev-pi board encoder.
 type EncoderConfig struct {
 	Name string `json:"pin_name"`
+	// Board is the revolution pi board the encoder is connected to. The encoder shares the board's process image.
+	// Without a board the encoder opens its own process image with Backend and PiCtoryConfig.
+	Board         string `json:"board,omitempty"`
+	Backend       string `json:"backend,omitempty"`
+	PiCtoryConfig string `json:"pictory_config,omitempty"`
+	// TicksPerRotation is the number of pulses per rotation of the encoder. Setting it enables positions in degrees.
+	TicksPerRotation int `json:"ticks_per_rotation,omitempty"`
+	// QuadratureMultiplier is the number of counts per pulse, for example 4 when every edge of both channels is counted.
+	// Defaults to 1.
+	QuadratureMultiplier int `json:"quadrature_multiplier,omitempty"`
+	// PositionFile is a file the position is saved to, so it survives restarts of the module.
+	PositionFile string `json:"position_file,omitempty"`
+	// VelocityWindowMs is the time over which the velocity DoCommand computes the velocity. Defaults to 1000.
+	VelocityWindowMs int `json:"velocity_window_ms,omitempty"`
 }
 
 func init() {
@@ -46,12 +78,83 @@ func (cfg *EncoderConfig) Validate(path string) ([]string, error) {
 	if cfg.Name == "" {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "pin_name")
 	}
-	return []string{}, nil
+	if cfg.Board != "" && (cfg.Backend != "" || cfg.PiCtoryConfig != "") {
+		return nil, resource.NewConfigValidationError(path,
+			errors.New("backend and pictory_config are taken from the board when board is set"))
+	}
+	if err := validateBackend(cfg.Backend); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	if cfg.TicksPerRotation < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("ticks_per_rotation cannot be negative"))
+	}
+	if cfg.QuadratureMultiplier < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("quadrature_multiplier cannot be negative"))
+	}
+	if cfg.VelocityWindowMs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("velocity_window_ms cannot be negative"))
+	}
+	if cfg.Board == "" {
+		return []string{}, nil
+	}
+	return []string{cfg.Board}, nil
+}
+
+// countsPerRotation returns the number of counts of the encoder for one rotation, or 0 if it is not configured.
+func (cfg *EncoderConfig) countsPerRotation() float64 {
+	multiplier := cfg.QuadratureMultiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+	return float64(cfg.TicksPerRotation * multiplier)
+}
+
+// revolutionPiBoardFromDependencies returns the revolution pi board with the given name.
+func revolutionPiBoardFromDependencies(deps resource.Dependencies, name string) (*revolutionPiBoard, error) {
+	b, err := board.FromDependencies(deps, name)
+	if err != nil {
+		return nil, err
+	}
+	revPi, ok := b.(*revolutionPiBoard)
+	if !ok {
+		return nil, fmt.Errorf("board %s is not a revolution pi board", name)
+	}
+	return revPi, nil
+}
+
+// openEncoderPin returns the counter of the encoder and the chip it uses, which the encoder has to release.
+// The counter is taken from the board if one is configured, otherwise the encoder opens its own chip.
+func openEncoderPin(
+	deps resource.Dependencies,
+	conf *EncoderConfig,
+	logger logging.Logger,
+) (*counterPin, *gpioChip, error) {
+	if conf.Board == "" {
+		chip, err := newGpioChip(conf.Backend, conf.PiCtoryConfig, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		enc, err := chip.GetEncoder(conf.Name)
+		if err != nil {
+			return nil, nil, multierr.Combine(err, chip.release())
+		}
+		return enc, chip, nil
+	}
+	revPi, err := revolutionPiBoardFromDependencies(deps, conf.Board)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := revPi.encoderPin(conf.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the chip stays open until the encoder closes, even if the board closes first
+	return enc, enc.controlChip.acquire(), nil
 }
 
 func newEncoder(
 	ctx context.Context,
-	_ resource.Dependencies,
+	deps resource.Dependencies,
 	conf resource.Config,
 	logger logging.Logger,
 ) (encoder.Encoder, error) {
@@ -59,64 +162,153 @@ func newEncoder(
 	if err != nil {
 		return nil, err
 	}
-	devPath := filepath.Clean(filepath.Join("/dev", "piControl0"))
-	fd, err := os.OpenFile(devPath, os.O_RDWR, fs.FileMode(os.O_RDWR))
+	enc, chip, err := openEncoderPin(deps, svcConfig, logger)
 	if err != nil {
-		err = fmt.Errorf("open chip %v failed: %w", devPath, err)
 		return nil, err
 	}
-	chip := gpioChip{dev: devPath, logger: logger, fileHandle: fd}
 
-	err = chip.showDeviceList()
+	// resets before the first read are part of the first value
+	resets := enc.resetCount()
+	raw, err := enc.Value()
 	if err != nil {
-		return nil, err
+		return nil, multierr.Combine(err, chip.release())
 	}
-	name := svcConfig.Name
-	pin := SPIVariable{strVarName: char32(name)}
-	err = chip.mapNameToAddress(&pin)
-	if err != nil {
-		return nil, err
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	e := &revolutionPiEncoder{
+		Named:            conf.ResourceName().AsNamed(),
+		logger:           logger,
+		pin:              enc,
+		chip:             chip,
+		positionFile:     svcConfig.PositionFile,
+		ticksPerRotation: svcConfig.countsPerRotation(),
+		velocityWindow:   defaultVelocityWindow,
+		resets:           resets,
+		cancelCtx:        cancelCtx,
+		cancelFunc:       cancelFunc,
+	}
+	if svcConfig.VelocityWindowMs > 0 {
+		e.velocityWindow = time.Duration(svcConfig.VelocityWindowMs) * time.Millisecond
+	}
+	if e.positionFile == "" {
+		e.position.set(raw, int64(int32(raw)))
+	} else if err := e.position.restore(e.positionFile, raw); err != nil {
+		cancelFunc()
+		return nil, multierr.Combine(err, chip.release())
 	}
+	e.startSampler()
+	return e, nil
+}
+
+// startSampler samples the counter from a background worker, so wraps are detected even when the position is
+// not read, and saves the position to the position file if one is configured.
+func (enc *revolutionPiEncoder) startSampler() {
+	enc.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		lastPersist := time.Now()
+		for utils.SelectContextOrWait(enc.cancelCtx, encoderSampleInterval) {
+			if _, err := enc.sample(); err != nil {
+				enc.logger.Debugf("failed to sample encoder: %v", err)
+				continue
+			}
+			if enc.positionFile != "" && time.Since(lastPersist) >= encoderPersistInterval {
+				lastPersist = time.Now()
+				if err := enc.position.persist(enc.positionFile); err != nil {
+					enc.logger.Warn(err)
+				}
+			}
+		}
+	}, enc.activeBackgroundWorkers.Done)
+}
+
+// sample reads the counter and returns the accumulated position.
+func (enc *revolutionPiEncoder) sample() (int64, error) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	return enc.sampleLocked()
+}
 
-	enc, err := initializeDigitalInterrupt(pin, &chip, true)
+// sampleLocked is sample for callers that hold enc.mu.
+func (enc *revolutionPiEncoder) sampleLocked() (int64, error) {
+	raw, err := enc.pin.Value()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-
-	return &revolutionPiEncoder{Named: conf.ResourceName().AsNamed(), pin: enc}, nil
+	// checked after the read, so a reset in between only makes raw a count from before the reset
+	if resets := enc.pin.resetCount(); resets != enc.resets {
+		// the board reset the counter, or piControl was reset, which may have restarted the counter
+		enc.position.counterReset(resets.counter != enc.resets.counter)
+		enc.resets = resets
+	}
+	position := enc.position.update(raw)
+	enc.addSample(time.Now(), position)
+	return position, nil
 }
 
 func (enc *revolutionPiEncoder) Position(ctx context.Context, positionType encoder.PositionType,
 	extra map[string]interface{},
 ) (float64, encoder.PositionType, error) {
-	pos, err := enc.pin.Value()
+	pos, err := enc.sample()
 	if err != nil {
 		return 0, encoder.PositionTypeTicks, err
 	}
-	// rev pi encoder values are int32, but Value() returns uint32. we cast the pos to int32 to fix this
-	signedPos := int32(pos) - enc.zeroPos.Load()
-
 	// encoder api expects float64
-	return float64(signedPos), encoder.PositionTypeTicks, nil
+	return toPositionType(float64(pos), enc.ticksPerRotation, positionType)
 }
 
+// toPositionType converts a position in ticks to the requested position type. ticksPerRotation is 0 if the
+// number of ticks per rotation is not known.
+func toPositionType(
+	ticks, ticksPerRotation float64, positionType encoder.PositionType,
+) (float64, encoder.PositionType, error) {
+	if positionType != encoder.PositionTypeDegrees {
+		return ticks, encoder.PositionTypeTicks, nil
+	}
+	if ticksPerRotation == 0 {
+		return 0, encoder.PositionTypeDegrees, errors.New("cannot report position in degrees, ticks_per_rotation is not set")
+	}
+	return ticks / ticksPerRotation * 360, encoder.PositionTypeDegrees, nil
+}
+
+// ResetPosition sets the counter on the DIO module back to 0. If the driver does not support resetting
+// the counter, only the accumulated position is set back to 0.
 func (enc *revolutionPiEncoder) ResetPosition(ctx context.Context, extra map[string]interface{}) error {
-	pos, err := enc.pin.Value()
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	resetErr := enc.pin.reset()
+	enc.samples = nil
+	if resetErr == nil {
+		enc.position.resetCounter()
+		// the encoder's own reset is accounted for already
+		enc.resets = enc.pin.resetCount()
+		return nil
+	}
+	enc.logger.Warnf("hardware counter reset failed, resetting the position in software instead: %v", resetErr)
+	raw, err := enc.pin.Value()
 	if err != nil {
 		return err
 	}
-	enc.zeroPos.Store(int32(pos))
+	enc.position.set(raw, 0)
 	return nil
 }
 
 func (enc *revolutionPiEncoder) Properties(ctx context.Context, extra map[string]interface{}) (encoder.Properties, error) {
-	return encoder.Properties{TicksCountSupported: true, AngleDegreesSupported: false}, nil
+	return encoder.Properties{TicksCountSupported: true, AngleDegreesSupported: enc.ticksPerRotation > 0}, nil
 }
 
 func (enc *revolutionPiEncoder) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
-	return nil, grpc.UnimplementedError
+	return runCommands(ctx, enc.commands(), req)
 }
 
 func (enc *revolutionPiEncoder) Close(ctx context.Context) error {
-	return enc.pin.controlChip.Close()
+	enc.cancelFunc()
+	enc.activeBackgroundWorkers.Wait()
+	var err error
+	if enc.positionFile != "" {
+		// count the last ticks before the final save
+		if _, sampleErr := enc.sample(); sampleErr != nil {
+			enc.logger.Debugf("failed to sample encoder: %v", sampleErr)
+		}
+		err = enc.position.persist(enc.positionFile)
+	}
+	return multierr.Combine(err, enc.chip.release())
 }
@@ -0,0 +1,113 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	velocityKey    = "velocity"
+	rawKey         = "raw"
+	setPositionKey = "setPosition"
+
+	// defaultVelocityWindow is the time over which the velocity is computed when no window is configured.
+	defaultVelocityWindow = time.Second
+)
+
+// positionSample is a position of the encoder and the time it was sampled.
+type positionSample struct {
+	time     time.Time
+	position int64
+}
+
+// addSample records a sample for the velocity and drops the samples that are older than the window,
+// keeping the newest of them so the velocity always spans the whole window.
+func (enc *revolutionPiEncoder) addSample(now time.Time, position int64) {
+	enc.samples = append(enc.samples, positionSample{time: now, position: position})
+	drop := 0
+	for drop+1 < len(enc.samples) && now.Sub(enc.samples[drop+1].time) >= enc.velocityWindow {
+		drop++
+	}
+	enc.samples = enc.samples[drop:]
+}
+
+// velocity returns the velocity in ticks per second between the oldest and newest sample in the window,
+// and the time between them.
+func (enc *revolutionPiEncoder) velocity() (float64, time.Duration, error) {
+	// a reset between the sample and the computation would leave no samples
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if _, err := enc.sampleLocked(); err != nil {
+		return 0, 0, err
+	}
+	first, last := enc.samples[0], enc.samples[len(enc.samples)-1]
+	elapsed := last.time.Sub(first.time)
+	if len(enc.samples) < 2 || elapsed <= 0 {
+		return 0, 0, errors.New("not enough samples to compute the velocity yet")
+	}
+	return float64(last.position-first.position) / elapsed.Seconds(), elapsed, nil
+}
+
+// commands returns the DoCommands supported by the encoder, in the order they are run.
+func (enc *revolutionPiEncoder) commands() []boardCommand {
+	return []boardCommand{
+		{key: setPositionKey, handler: enc.setPosition},
+		{key: rawKey, handler: enc.raw},
+		{key: velocityKey, handler: enc.velocityCommand},
+	}
+}
+
+// velocityCommand returns the velocity in ticks per second, and in rotations per minute if ticks_per_rotation is set.
+func (enc *revolutionPiEncoder) velocityCommand(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	ticksPerSec, elapsed, err := enc.velocity()
+	if err != nil {
+		return err
+	}
+	velocity := map[string]interface{}{
+		"ticks_per_sec": ticksPerSec,
+		"window_ms":     float64(elapsed) / float64(time.Millisecond),
+	}
+	if enc.ticksPerRotation > 0 {
+		velocity["rpm"] = ticksPerSec / enc.ticksPerRotation * 60
+	}
+	resp[velocityKey] = velocity
+	return nil
+}
+
+// raw returns the unmodified value of the hardware counter.
+func (enc *revolutionPiEncoder) raw(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	value, err := enc.pin.Value()
+	if err != nil {
+		return err
+	}
+	resp[rawKey] = value
+	return nil
+}
+
+// setPosition presets the position to the given number of ticks. The hardware counter is not changed.
+func (enc *revolutionPiEncoder) setPosition(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	position, ok := value.(float64)
+	if !ok || position != math.Trunc(position) {
+		return fmt.Errorf("error performing %s: expected an integer got %v", setPositionKey, value)
+	}
+	if math.Abs(position) >= 1<<63 {
+		return fmt.Errorf("error performing %s: %v does not fit in 64 bits", setPositionKey, value)
+	}
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	raw, err := enc.pin.Value()
+	if err != nil {
+		return err
+	}
+	enc.position.set(raw, int64(position))
+	// the samples before the preset would show a jump in the velocity
+	enc.samples = []positionSample{{time: time.Now(), position: int64(position)}}
+	resp[setPositionKey] = position
+	return nil
+}
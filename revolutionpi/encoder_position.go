@@ -0,0 +1,196 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// encoderSampleInterval is how often the encoder samples its counter in the background, so a counter that
+	// wraps around while nobody reads the position is still accounted for.
+	encoderSampleInterval = 100 * time.Millisecond
+	// encoderPersistInterval is how often a changed position is written to the position file.
+	encoderPersistInterval = time.Second
+	// counterResetTimeout is how long samples may still show the count from before a hardware reset.
+	counterResetTimeout = time.Second
+	bootIDPath          = "/proc/sys/kernel/random/boot_id"
+)
+
+// positionAccumulator extends the 32 bit hardware counter of an encoder to a 64 bit position.
+// The counter is sampled often enough that it never moves more than 2^31 ticks between samples,
+// so the difference between two samples is always the distance travelled, even across a wrap.
+type positionAccumulator struct {
+	mu       sync.Mutex
+	lastRaw  uint32
+	position int64
+	// whether position changed since it was last persisted
+	dirty bool
+	// resetPending is set after the hardware counter was or may have been reset until a sample shows the reset,
+	// resetKnown if the counter was reset for sure. staleRaw is the count before the reset and resetExpires is
+	// when the samples are taken as they are again.
+	resetPending bool
+	resetKnown   bool
+	staleRaw     uint32
+	resetExpires time.Time
+}
+
+// update adds the distance travelled since the last sample to the position and returns the new position.
+func (acc *positionAccumulator) update(raw uint32) int64 {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	if acc.resetPending {
+		switch {
+		case ticksBetween(raw, 0) <= ticksBetween(raw, acc.staleRaw):
+			// the counter started over, so it is counted from 0
+			acc.lastRaw = 0
+			acc.resetPending = false
+		case time.Now().Before(acc.resetExpires):
+			if acc.resetKnown {
+				// the sample was taken before the reset reached the process image
+				return acc.position
+			}
+		default:
+			if acc.resetKnown {
+				// the counter was not reset after all, so it is counted from its current value
+				acc.lastRaw = raw
+			}
+			acc.resetPending = false
+		}
+	}
+	delta := int32(raw - acc.lastRaw)
+	acc.lastRaw = raw
+	if delta != 0 {
+		acc.position += int64(delta)
+		acc.dirty = true
+	}
+	return acc.position
+}
+
+// set makes raw the current value of the counter and position the current position.
+func (acc *positionAccumulator) set(raw uint32, position int64) {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	acc.lastRaw = raw
+	acc.position = position
+	acc.dirty = true
+	acc.resetPending = false
+}
+
+// resetCounter sets the position to 0 after the hardware counter was reset. The process image, and the
+// snapshot of the board when read_cycle_ms is set, can still show the old count for a cycle, so samples
+// that are closer to the old count than to 0 are ignored until the reset shows up or counterResetTimeout passes.
+func (acc *positionAccumulator) resetCounter() {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	acc.position = 0
+	acc.dirty = true
+	acc.counterResetLocked(true)
+}
+
+// counterReset keeps the position when the hardware counter was reset by someone else, for example the
+// resetCounter DoCommand of the board. If known is false, the counter may have been reset, for example by a
+// reset of piControl, so samples closer to the old count than to 0 are still counted as usual.
+func (acc *positionAccumulator) counterReset(known bool) {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	acc.counterResetLocked(known)
+}
+
+// counterResetLocked waits for a sample that shows the counter starting over.
+func (acc *positionAccumulator) counterResetLocked(known bool) {
+	acc.staleRaw = acc.lastRaw
+	if known {
+		acc.lastRaw = 0
+	}
+	acc.resetPending = true
+	acc.resetKnown = known
+	acc.resetExpires = time.Now().Add(counterResetTimeout)
+}
+
+// ticksBetween returns the distance between two counter values, taking the shorter way across a wrap.
+func ticksBetween(a, b uint32) uint32 {
+	if delta := int32(a - b); delta < 0 {
+		return uint32(-int64(delta))
+	}
+	return a - b
+}
+
+// persistedPosition is the content of an encoder position file.
+type persistedPosition struct {
+	Position int64  `json:"position"`
+	Raw      uint32 `json:"raw"`
+	BootID   string `json:"boot_id"`
+}
+
+// bootID identifies the current boot. DIO counters start at 0 after every boot, so a position saved
+// during another boot cannot be continued from the saved counter value.
+func bootID() string {
+	id, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(id))
+}
+
+// restore continues the position saved in path. If the counter kept running since the position was saved,
+// the ticks counted while the module was not running are added. A missing file starts at 0.
+func (acc *positionAccumulator) restore(path string, raw uint32) error {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if errors.Is(err, os.ErrNotExist) {
+		acc.set(raw, int64(int32(raw)))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read encoder position: %w", err)
+	}
+	var saved persistedPosition
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse encoder position %s: %w", path, err)
+	}
+	lastRaw := saved.Raw
+	if saved.BootID == "" || saved.BootID != bootID() {
+		// the counter restarted at 0 when the Revolution Pi booted
+		lastRaw = 0
+	}
+	acc.set(lastRaw, saved.Position)
+	acc.update(raw)
+	return nil
+}
+
+// persist writes the position to path if it changed since it was last written.
+func (acc *positionAccumulator) persist(path string) error {
+	acc.mu.Lock()
+	if !acc.dirty {
+		acc.mu.Unlock()
+		return nil
+	}
+	saved := persistedPosition{Position: acc.position, Raw: acc.lastRaw, BootID: bootID()}
+	acc.dirty = false
+	acc.mu.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write encoder position: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path, so a crash never leaves it half written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPositionAccumulatorWrap(t *testing.T) {
+	var acc positionAccumulator
+	acc.set(1<<32-10, 100)
+	if position := acc.update(5); position != 115 {
+		t.Errorf("position is %d after the counter wrapped, expected 115", position)
+	}
+	if position := acc.update(1<<32 - 5); position != 105 {
+		t.Errorf("position is %d after the counter wrapped back, expected 105", position)
+	}
+}
+
+func TestPositionAccumulatorResetCounter(t *testing.T) {
+	var acc positionAccumulator
+	acc.set(0, 0)
+	acc.update(100000)
+	acc.resetCounter()
+	// samples from before the reset reached the process image
+	for _, raw := range []uint32{100000, 100020} {
+		if position := acc.update(raw); position != 0 {
+			t.Errorf("position is %d for the stale count %d, expected 0", position, raw)
+		}
+	}
+	if position := acc.update(3); position != 3 {
+		t.Errorf("position is %d after the reset, expected 3", position)
+	}
+	if position := acc.update(10); position != 10 {
+		t.Errorf("position is %d, expected 10", position)
+	}
+
+	// a counter that never shows the reset is counted from its value once the reset expires
+	acc.resetCounter()
+	acc.resetExpires = time.Now()
+	if position := acc.update(12); position != 0 {
+		t.Errorf("position is %d after the reset expired, expected 0", position)
+	}
+	if position := acc.update(15); position != 3 {
+		t.Errorf("position is %d, expected 3", position)
+	}
+}
+
+func TestPositionAccumulatorCounterReset(t *testing.T) {
+	var acc positionAccumulator
+	acc.set(0, 0)
+	acc.update(1000)
+	// a reset of the counter by the board keeps the position
+	acc.counterReset(true)
+	if position := acc.update(1000); position != 1000 {
+		t.Errorf("position is %d for the stale count, expected 1000", position)
+	}
+	if position := acc.update(5); position != 1005 {
+		t.Errorf("position is %d after the reset, expected 1005", position)
+	}
+
+	// a reset of piControl may not have restarted the counter, so the samples are still counted
+	acc.counterReset(false)
+	if position := acc.update(1005); position != 2005 {
+		t.Errorf("position is %d, expected 2005", position)
+	}
+	if position := acc.update(2); position != 2007 {
+		t.Errorf("position is %d after the counter restarted, expected 2007", position)
+	}
+}
@@ -0,0 +1,134 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"sync"
+	"testing"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/encoder"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+// newSimulatedEncoder returns an encoder on I_1 of a simulated board, closed when the test ends.
+func newSimulatedEncoder(t *testing.T, b *revolutionPiBoard, conf *EncoderConfig) *revolutionPiEncoder {
+	t.Helper()
+	writeVariable(t, b.controlChip, "InputMode_1", []byte{3})
+	conf.Name = "I_1"
+	conf.Board = "board"
+	enc, err := newEncoder(context.Background(), resource.Dependencies{board.Named("board"): b},
+		resource.Config{Name: "encoder", API: encoder.API, Model: EncoderModel, ConvertedAttributes: conf},
+		logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := enc.Close(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return enc.(*revolutionPiEncoder)
+}
+
+func TestEncoderVelocityDuringReset(t *testing.T) {
+	ctx := context.Background()
+	enc := newSimulatedEncoder(t, newSimulatedBoard(t, &Config{}), &EncoderConfig{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if err := enc.ResetPosition(ctx, nil); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		// right after a reset there are not enough samples, which is an error rather than a panic
+		//nolint:errcheck
+		enc.velocity()
+	}
+	wg.Wait()
+
+	if err := enc.ResetPosition(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := enc.velocity(); err == nil {
+		t.Error("expected an error computing the velocity from a single sample")
+	}
+}
+
+func TestEncoderWithoutBoard(t *testing.T) {
+	ctx := context.Background()
+	// a PiCtory configuration with I_1 in encoder mode
+	path := writeDIOConfig(t, 1)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`["InputMode_1","0"`), []byte(`["InputMode_1","3"`), 1)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &EncoderConfig{Name: "I_1", Backend: backendSimulated, PiCtoryConfig: path}
+	deps, err := conf.Validate("encoder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 0 {
+		t.Errorf("an encoder without a board depends on %v", deps)
+	}
+	if _, err := (&EncoderConfig{Name: "I_1", Board: "board", Backend: backendSimulated}).Validate("encoder"); err == nil {
+		t.Error("expected an error setting backend together with board")
+	}
+
+	res, err := newEncoder(ctx, nil,
+		resource.Config{Name: "encoder", API: encoder.API, Model: EncoderModel, ConvertedAttributes: conf},
+		logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := res.(*revolutionPiEncoder)
+	counter := make([]byte, 4)
+	binary.LittleEndian.PutUint32(counter, 42)
+	writeVariable(t, enc.chip, "Counter_1", counter)
+	if position, _, err := enc.Position(ctx, encoder.PositionTypeTicks, nil); err != nil || position != 42 {
+		t.Errorf("position is %v, %v, expected 42", position, err)
+	}
+	if err := enc.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderBoardResetsCounter(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+	enc := newSimulatedEncoder(t, b, &EncoderConfig{})
+	counter := make([]byte, 4)
+	binary.LittleEndian.PutUint32(counter, 1000)
+	writeVariable(t, b.controlChip, "Counter_1", counter)
+	if position, _, err := enc.Position(ctx, encoder.PositionTypeTicks, nil); err != nil || position != 1000 {
+		t.Fatalf("position is %v, %v, expected 1000", position, err)
+	}
+
+	if _, err := b.DoCommand(ctx, map[string]interface{}{resetCounterKey: "I_1"}); err != nil {
+		t.Fatal(err)
+	}
+	if position, _, err := enc.Position(ctx, encoder.PositionTypeTicks, nil); err != nil || position != 1000 {
+		t.Errorf("position is %v, %v after the board reset the counter, expected 1000", position, err)
+	}
+	binary.LittleEndian.PutUint32(counter, 7)
+	writeVariable(t, b.controlChip, "Counter_1", counter)
+	if position, _, err := enc.Position(ctx, encoder.PositionTypeTicks, nil); err != nil || position != 1007 {
+		t.Errorf("position is %v, %v, expected 1007", position, err)
+	}
+}
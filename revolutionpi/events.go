@@ -0,0 +1,242 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/utils"
+)
+
+const (
+	eventsKey = "events"
+	// maxEventHistory is the number of piControl events kept by the board, older events are dropped.
+	maxEventHistory = 100
+	// ownResetWindow is how long after the board resets piControl a reset event is attributed to the board.
+	ownResetWindow = 2 * time.Second
+)
+
+// piControlEvent is an event reported by kbWaitForEvent.
+type piControlEvent int32
+
+// piControlEventReset is reported after the driver was reset, for example by PiCtory or another process.
+const piControlEventReset piControlEvent = 1
+
+func (e piControlEvent) String() string {
+	switch e {
+	case piControlEventReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("unknown (%d)", int32(e))
+	}
+}
+
+// eventRecord is an entry of the board's event history.
+type eventRecord struct {
+	time  time.Time
+	event piControlEvent
+	// whether a board reloaded its configuration after the event, and the result of re-validating it
+	reloaded      bool
+	validationErr error
+}
+
+func (r eventRecord) toMap() map[string]interface{} {
+	record := map[string]interface{}{
+		"time":  r.time.Format(time.RFC3339Nano),
+		"event": r.event.String(),
+		"code":  int32(r.event),
+	}
+	if r.reloaded {
+		record["valid"] = r.validationErr == nil
+	}
+	if r.validationErr != nil {
+		record["error"] = r.validationErr.Error()
+	}
+	return record
+}
+
+// eventListener waits for the events of a piControl device for every board of the module that uses it.
+// kbWaitForEvent cannot be cancelled, not even by closing the handle, so the listener of a piControl device is
+// started once and keeps its handle and a blocked ioctl for the lifetime of the module. Boards only subscribe to
+// it, so rebuilding a board neither leaves another wait behind nor loses the event history. Every simulated board
+// has its own process image, which wakes the wait when it closes, so its listener stops with the board.
+type eventListener struct {
+	logger logging.Logger
+	// chip is the handle the listener waits on
+	chip *gpioChip
+	// mu is held while an event is handled, so a board that unsubscribes is no longer reloaded afterwards
+	mu     sync.Mutex
+	boards []*revolutionPiBoard
+	// piControl events received by the listener, oldest first
+	history []eventRecord
+	stopped bool
+}
+
+var (
+	eventListenersMu sync.Mutex
+	// eventListeners are the listeners of the piControl devices by device path
+	eventListeners = map[string]*eventListener{}
+)
+
+// subscribeEvents adds the board to the listener of its device and starts the listener if there is none yet.
+func (b *revolutionPiBoard) subscribeEvents() {
+	g := b.controlChip
+	eventListenersMu.Lock()
+	l, ok := eventListeners[g.dev]
+	if !ok || g.dev == backendSimulated {
+		l = &eventListener{logger: b.logger, chip: g.acquire()}
+		if g.dev != backendSimulated {
+			eventListeners[g.dev] = l
+		}
+		l.start()
+	}
+	eventListenersMu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.boards = append(l.boards, b)
+	b.listener = l
+}
+
+// unsubscribeEvents removes the board from its listener. The listener of a simulated image releases its chip,
+// which wakes the wait once the image closes.
+func (b *revolutionPiBoard) unsubscribeEvents() error {
+	l := b.listener
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, subscribed := range l.boards {
+		if subscribed == b {
+			l.boards = append(l.boards[:i], l.boards[i+1:]...)
+			break
+		}
+	}
+	if l.chip.dev != backendSimulated || l.stopped {
+		return nil
+	}
+	l.stopped = true
+	return l.chip.release()
+}
+
+// start waits for piControl events from a background goroutine. Every event is handled for the boards subscribed
+// at the time. The goroutine stops if piControl does not support events, or once the listener of a simulated
+// image is stopped.
+func (l *eventListener) start() {
+	utils.PanicCapturingGo(func() {
+		for {
+			var event int32
+			//nolint:gosec
+			err := l.chip.ioCtl(uintptr(kbWaitForEvent), unsafe.Pointer(&event))
+			if err == nil {
+				l.handleEvent(piControlEvent(event))
+				continue
+			}
+			if l.stop(err) {
+				return
+			}
+			l.logger.Warnf("failed to wait for piControl events: %v", err)
+			time.Sleep(time.Second)
+		}
+	})
+}
+
+// stop reports whether the listener stops after err, releasing its chip if it was not released yet.
+func (l *eventListener) stop(err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return true
+	}
+	if !errors.Is(err, syscall.ENOTTY) && !errors.Is(err, syscall.EINVAL) {
+		return false
+	}
+	l.logger.Warnf("piControl does not support events, configuration changes will not be detected: %v", err)
+	eventListenersMu.Lock()
+	if eventListeners[l.chip.dev] == l {
+		delete(eventListeners, l.chip.dev)
+	}
+	eventListenersMu.Unlock()
+	l.stopped = true
+	if err := l.chip.release(); err != nil {
+		l.logger.Debugf("failed to close the event listener's handle: %v", err)
+	}
+	return true
+}
+
+// ownResetEvent reports whether a reset event was caused by the board's own reset, which reloaded the
+// configuration already. Only the first reset event after the board's reset is attributed to it.
+func (g *gpioChip) ownResetEvent(event piControlEvent) bool {
+	if event != piControlEventReset {
+		return false
+	}
+	reset := g.ownReset.Swap(0)
+	return reset != 0 && time.Since(time.Unix(0, reset)) < ownResetWindow
+}
+
+// handleEvent re-validates the configuration of every subscribed board after a piControl event and records the
+// result, since the driver may have been reset with a new configuration, for example after it was saved in PiCtory.
+// An event that only the board that reset piControl is subscribed to is not recorded, that board reloaded its
+// configuration already.
+func (l *eventListener) handleEvent(event piControlEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record := eventRecord{time: time.Now(), event: event}
+	for _, b := range l.boards {
+		if b.controlChip.ownResetEvent(event) {
+			b.logger.Debug("ignoring the reset event caused by reloading the configuration")
+			continue
+		}
+		record.reloaded = true
+		record.validationErr = multierr.Append(record.validationErr, b.reloadForEvent(event))
+	}
+	if len(l.boards) > 0 && !record.reloaded {
+		return
+	}
+	if len(l.boards) == 0 {
+		l.logger.Infof("received piControl event %v while no board is running", event)
+	}
+	l.history = append(l.history, record)
+	if len(l.history) > maxEventHistory {
+		l.history = l.history[len(l.history)-maxEventHistory:]
+	}
+}
+
+// reloadForEvent reloads the board's configuration after a piControl event.
+func (b *revolutionPiBoard) reloadForEvent(event piControlEvent) error {
+	if event == piControlEventReset {
+		b.logger.Info("piControl was reset, reloading the configuration")
+		b.controlChip.noteDriverReset()
+	} else {
+		b.logger.Warnf("received piControl event %v, reloading the configuration", event)
+	}
+	// the driver already reloaded its configuration, so only the board needs to catch up
+	err := b.reloadConfig(false)
+	if err != nil {
+		b.logger.Errorf("configuration is not valid after piControl event %v: %v", event, err)
+	}
+	return err
+}
+
+// events returns the piControl events received since the module started, oldest first.
+func (b *revolutionPiBoard) events(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	l := b.listener
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make([]interface{}, 0, len(l.history))
+	for _, record := range l.history {
+		records = append(records, record.toMap())
+	}
+	resp[eventsKey] = records
+	return nil
+}
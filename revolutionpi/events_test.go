@@ -0,0 +1,52 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// eventCount returns the number of events in the board's event history.
+func eventCount(t *testing.T, b *revolutionPiBoard) int {
+	t.Helper()
+	resp, err := b.DoCommand(context.Background(), map[string]interface{}{eventsKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(resp[eventsKey].([]interface{}))
+}
+
+func TestEventsIgnoreOwnReset(t *testing.T) {
+	b := newSimulatedBoard(t, &Config{})
+	// the events are handled directly, the listener only waits for events of the simulated image
+	b.controlChip.ownReset.Store(time.Now().UnixNano())
+	b.listener.handleEvent(piControlEventReset)
+	if n := eventCount(t, b); n != 0 {
+		t.Errorf("the board's own reset was recorded as %d events", n)
+	}
+
+	// a reset by another process
+	b.listener.handleEvent(piControlEventReset)
+	if n := eventCount(t, b); n != 1 {
+		t.Errorf("the external reset was recorded as %d events", n)
+	}
+}
+
+func TestEventsWithoutBoard(t *testing.T) {
+	b := newSimulatedBoard(t, &Config{})
+	l := b.listener
+	if err := b.unsubscribeEvents(); err != nil {
+		t.Fatal(err)
+	}
+	// an event between the close and the rebuild of a board is still recorded
+	l.handleEvent(piControlEventReset)
+	records := l.history
+	if len(records) != 1 {
+		t.Fatalf("recorded %d events, expected 1", len(records))
+	}
+	if _, ok := records[0].toMap()["valid"]; ok {
+		t.Error("an event that no board reloaded its configuration for is reported as validated")
+	}
+}
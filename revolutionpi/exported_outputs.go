@@ -0,0 +1,160 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"go.uber.org/multierr"
+	"go.viam.com/utils"
+)
+
+// defaultExportedOutputsCycle is how often the application image is pushed when no cycle is configured.
+const defaultExportedOutputsCycle = 10 * time.Millisecond
+
+// exportedOutputs is an application side copy of the process image. While it is enabled, outputs are written
+// to this image instead of the real process image and pushed to piControl with kbSetExportedOutputs, so the board
+// only ever changes the outputs it was allowed to and leaves the outputs of a PLC runtime alone.
+type exportedOutputs struct {
+	mu    sync.Mutex
+	names []string
+	// the variables the board may write, resolved from names
+	allowed []SPIVariable
+	image   [processImageSize]byte
+}
+
+// enableExportedOutputs switches the chip to the exported outputs mode for the given output variables.
+// The application image starts out with the current values of the process image.
+func (g *gpioChip) enableExportedOutputs(names []string) error {
+	exported := &exportedOutputs{names: names}
+	if err := g.resolveExportedOutputs(exported); err != nil {
+		return err
+	}
+	if _, err := g.readAt(exported.image[:], 0); err != nil {
+		return fmt.Errorf("failed to read process image for exported outputs: %w", err)
+	}
+	g.exported = exported
+	return nil
+}
+
+// resolveExportedOutputs looks up the addresses of the allow-listed output variables.
+func (g *gpioChip) resolveExportedOutputs(exported *exportedOutputs) error {
+	deviceInfoList, err := g.deviceInfoList()
+	if err != nil {
+		return err
+	}
+	var errs error
+	allowed := make([]SPIVariable, 0, len(exported.names))
+	for _, name := range exported.names {
+		pin := SPIVariable{strVarName: char32(name)}
+		if err := g.mapNameToAddress(&pin); err != nil {
+			errs = multierr.Combine(errs, fmt.Errorf("exported output %s: %w", name, err))
+			continue
+		}
+		if !isOutput(pin, deviceInfoList) {
+			errs = multierr.Combine(errs, fmt.Errorf("exported output %s is not an output", name))
+			continue
+		}
+		allowed = append(allowed, pin)
+	}
+	exported.mu.Lock()
+	defer exported.mu.Unlock()
+	exported.allowed = allowed
+	return errs
+}
+
+// isOutput checks whether a variable is in the output section of one of the devices.
+func isOutput(pin SPIVariable, deviceInfoList []SDeviceInfo) bool {
+	for _, dev := range deviceInfoList {
+		if pin.i16uAddress >= dev.i16uOutputOffset && pin.i16uAddress < dev.i16uOutputOffset+dev.i16uOutputLength {
+			return true
+		}
+	}
+	return false
+}
+
+// write stores b in the application image if it falls within a single allow-listed variable.
+func (e *exportedOutputs) write(address int64, b []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range e.allowed {
+		start := int64(v.i16uAddress)
+		if v.i16uLength >= 8 && address >= start && address+int64(len(b)) <= start+int64(v.i16uLength/8) {
+			copy(e.image[address:], b)
+			return nil
+		}
+	}
+	return fmt.Errorf("output at address %d is not an exported output", address)
+}
+
+// setBit sets a single bit of the application image if it belongs to an allow-listed variable.
+func (e *exportedOutputs) setBit(address uint16, bit uint8, high bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range e.allowed {
+		matchesBit := v.i16uLength == 1 && v.i16uAddress == address && v.i8uBit == bit
+		inBytes := v.i16uLength >= 8 && address >= v.i16uAddress && address < v.i16uAddress+v.i16uLength/8
+		if matchesBit || inBytes {
+			if high {
+				e.image[address] |= 1 << bit
+			} else {
+				e.image[address] &^= 1 << bit
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("output at address %d bit %d is not an exported output", address, bit)
+}
+
+// overlay copies the allow-listed variables of the application image into image.
+func (e *exportedOutputs) overlay(image *[processImageSize]byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range e.allowed {
+		if v.i16uLength == 1 {
+			mask := byte(1) << v.i8uBit
+			image[v.i16uAddress] = image[v.i16uAddress]&^mask | e.image[v.i16uAddress]&mask
+			continue
+		}
+		copy(image[v.i16uAddress:v.i16uAddress+v.i16uLength/8], e.image[v.i16uAddress:])
+	}
+}
+
+// pushExportedOutputs copies the allow-listed outputs into the process image with kbSetExportedOutputs.
+// Every other exported output is pushed with the value it currently has, so outputs written by a PLC runtime are kept.
+// The value is read from the process image itself, never from the snapshot of read_cycle_ms, which can be a cycle old.
+// The read and kbSetExportedOutputs are still two calls, so an output the PLC runtime writes in between is set back
+// to the value it had before.
+func (g *gpioChip) pushExportedOutputs() error {
+	var image [processImageSize]byte
+	if _, err := g.image.ReadAt(image[:], 0); err != nil {
+		return g.withDriverMessage(err)
+	}
+	g.exported.overlay(&image)
+	//nolint:gosec
+	err := g.ioCtl(uintptr(kbSetExportedOutputs), unsafe.Pointer(&image))
+	if err != nil {
+		return fmt.Errorf("failed to set exported outputs on %v: %w", g.dev, err)
+	}
+	return nil
+}
+
+// startExportedOutputs pushes the application image from a background worker once per cycle until the board closes.
+func (b *revolutionPiBoard) startExportedOutputs(cycle time.Duration) {
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for utils.SelectContextOrWait(b.cancelCtx, cycle) {
+			b.controlChip.configMu.RLock()
+			err := b.controlChip.pushExportedOutputs()
+			b.controlChip.configMu.RUnlock()
+			if err != nil {
+				b.logger.Warnf("failed to push exported outputs: %v", err)
+			}
+		}
+	}, b.activeBackgroundWorkers.Done)
+	b.logger.Infof("pushing exported outputs %v every %v", b.controlChip.exported.names, cycle)
+}
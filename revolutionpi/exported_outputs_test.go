@@ -0,0 +1,24 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportedOutputsKeepRuntimeOutputs(t *testing.T) {
+	// the snapshot is not refreshed during the test
+	b := newSimulatedBoard(t, &Config{ExportedOutputs: []string{"O_1"}, ExportedOutputsCycleMs: 1, ReadCycleMs: 10000})
+	// O_2 is driven by the PLC runtime
+	writeVariable(t, b.controlChip, "O_2", []byte{0b10})
+	time.Sleep(20 * time.Millisecond)
+
+	output := []byte{0}
+	if _, err := b.controlChip.image.ReadAt(output, int64(b.controlChip.dioDevices[0].i16uOutputOffset)); err != nil {
+		t.Fatal(err)
+	}
+	if output[0]&0b10 == 0 {
+		t.Error("pushing the exported outputs reset O_2, which the board does not own")
+	}
+}
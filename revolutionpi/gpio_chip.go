@@ -4,22 +4,81 @@
 package revolutionpi
 
 import (
+	"bytes"
 	"fmt"
-	"os"
-	"syscall"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"go.uber.org/multierr"
 	"go.viam.com/rdk/logging"
-	"golang.org/x/sys/unix"
 )
 
 type gpioChip struct {
+	// configMu is held for writing while the configuration is reloaded. Pin operations hold it for reading,
+	// so they never see a half updated device list.
+	configMu   sync.RWMutex
 	dev        string
+	configPath string // the PiCtory config.rsc describing the process image, if there is one
 	logger     logging.Logger
-	fileHandle *os.File
+	image      processImage
 	dioDevices []SDeviceInfo
 	aioDevices []SDeviceInfo
+
+	simulateInputs bool        // allow software to write to input pins while IO communication is stopped
+	ioStopped      atomic.Bool // whether piControl's IO communication was stopped with kbStopIO
+
+	// exported is the application image outputs are written to in the exported outputs mode, nil otherwise
+	exported *exportedOutputs
+	// writeMu serializes writes by the board, so read-modify-write transactions do not lose concurrent writes
+	writeMu sync.Mutex
+	// lastWrite is one more than the address of the byte the board wrote last, 0 before the first write.
+	// It is guarded by writeMu.
+	lastWrite int64
+	// cache is the process image snapshot reads are served from in the cached read mode, nil otherwise
+	cache *readCache
+	// refs is the number of users sharing the chip, the board and its encoders. The last one to release it closes it.
+	refs atomic.Int32
+	// ownReset is when the board last reset piControl in unix nanoseconds, so the reset event it causes is not
+	// mistaken for a reset by another process
+	ownReset atomic.Int64
+	// resetsMu guards resets, which counts the counter resets and piControl resets made or seen by the board,
+	// so encoders sharing the chip notice when their counter starts over
+	resetsMu sync.Mutex
+	resets   counterResets
+}
+
+// newGpioChip opens the process image of the given backend and validates the device configuration.
+func newGpioChip(backend, configPath string, logger logging.Logger) (*gpioChip, error) {
+	devPath, image, err := openProcessImage(backend, configPath)
+	if err != nil {
+		return nil, err
+	}
+	if configPath == "" && backend != backendSimulated {
+		configPath = defaultPiCtoryConfig
+	}
+	chip := gpioChip{dev: devPath, configPath: configPath, logger: logger, image: image}
+	err = chip.showDeviceList()
+	if err != nil {
+		return nil, multierr.Combine(err, chip.Close())
+	}
+	chip.refs.Store(1)
+	return &chip, nil
+}
+
+// acquire adds a user of the chip, which has to call release once it no longer uses the chip.
+func (g *gpioChip) acquire() *gpioChip {
+	g.refs.Add(1)
+	return g
+}
+
+// release removes a user of the chip and closes the chip if it was the last one.
+func (g *gpioChip) release() error {
+	if g.refs.Add(-1) > 0 {
+		return nil
+	}
+	return g.Close()
 }
 
 func (g *gpioChip) GetGPIOPin(pinName string) (*gpioPin, error) {
@@ -68,11 +127,42 @@ func (g *gpioChip) GetDigitalInterrupt(pinName string) (*counterPin, error) {
 	return initializeDigitalInterrupt(pin, g, false)
 }
 
+// GetEncoder returns a counter that is configured for encoder mode.
+func (g *gpioChip) GetEncoder(pinName string) (*counterPin, error) {
+	pin := SPIVariable{strVarName: char32(pinName)}
+	err := g.mapNameToAddress(&pin)
+	if err != nil {
+		return nil, err
+	}
+
+	return initializeDigitalInterrupt(pin, g, true)
+}
+
+// GetCounter returns a counter that is configured for either counter/interrupt or encoder mode.
+func (g *gpioChip) GetCounter(pinName string) (*counterPin, error) {
+	pin := SPIVariable{strVarName: char32(pinName)}
+	err := g.mapNameToAddress(&pin)
+	if err != nil {
+		return nil, err
+	}
+
+	counter, err := initializeDigitalInterrupt(pin, g, false)
+	if err != nil {
+		// the counter may be part of an encoder instead
+		encoder, encoderErr := initializeDigitalInterrupt(pin, g, true)
+		if encoderErr != nil {
+			return nil, err
+		}
+		return encoder, nil
+	}
+	return counter, nil
+}
+
 func (g *gpioChip) mapNameToAddress(pin *SPIVariable) error {
 	g.logger.Debugf("Looking for address of %#v", pin)
 	//nolint:gosec
 	err := g.ioCtl(uintptr(kbFindVariable), unsafe.Pointer(pin))
-	if err != 0 {
+	if err != nil {
 		e := fmt.Errorf("failed to get pin address info %v failed: %w", g.dev, err)
 		return e
 	}
@@ -82,18 +172,15 @@ func (g *gpioChip) mapNameToAddress(pin *SPIVariable) error {
 
 // showDeviceList reads the list of devices from the rev pi and validates the configuration is correct.
 func (g *gpioChip) showDeviceList() error {
-	var deviceInfoList [255]SDeviceInfo
 	g.dioDevices = []SDeviceInfo{}
 	g.aioDevices = []SDeviceInfo{}
-	//nolint:gosec
-	cnt, _, err := g.ioCtlReturns(uintptr(kbGetDeviceInfoList), unsafe.Pointer(&deviceInfoList))
-	if err != 0 {
-		e := fmt.Errorf("failed to retrieve device info list: %d", -int(cnt))
-		return e
+	deviceInfoList, err := g.deviceInfoList()
+	if err != nil {
+		return err
 	}
 
 	var deviceErrs error
-	for i := 0; i < int(cnt); i++ {
+	for i := range deviceInfoList {
 		if deviceInfoList[i].i8uActive != 0 {
 			g.logger.Debugf("device %d is of type %s is active", i, getModuleName(deviceInfoList[i].i16uModuleType))
 			if deviceInfoList[i].isDIO() {
@@ -118,38 +205,155 @@ func (g *gpioChip) showDeviceList() error {
 	return deviceErrs
 }
 
-func (g *gpioChip) ioCtl(command uintptr, message unsafe.Pointer) syscall.Errno {
-	_, _, err := g.ioCtlReturns(command, message)
+// deviceInfoList returns every device known to piControl, including devices that are not active.
+func (g *gpioChip) deviceInfoList() ([]SDeviceInfo, error) {
+	var deviceInfoList [255]SDeviceInfo
+	//nolint:gosec
+	cnt, err := g.ioCtlReturns(uintptr(kbGetDeviceInfoList), unsafe.Pointer(&deviceInfoList))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve device info list: %w", err)
+	}
+	return deviceInfoList[:cnt], nil
+}
+
+// deviceInfo returns the device at the given address.
+func (g *gpioChip) deviceInfo(address uint8) (SDeviceInfo, error) {
+	dev := SDeviceInfo{i8uAddress: address}
+	//nolint:gosec
+	err := g.ioCtl(uintptr(kbGetDeviceInfo), unsafe.Pointer(&dev))
+	if err != nil {
+		return SDeviceInfo{}, fmt.Errorf("failed to retrieve device info of address %d: %w", address, err)
+	}
+	return dev, nil
+}
+
+func (g *gpioChip) ioCtl(command uintptr, message unsafe.Pointer) error {
+	_, err := g.ioCtlReturns(command, message)
 	return err
 }
 
-func (g *gpioChip) ioCtlReturns(command uintptr, message unsafe.Pointer) (uintptr, uintptr, syscall.Errno) {
-	handle := g.fileHandle.Fd()
-	g.logger.Debugf("Handle: %#v, Command: %#v, Message: %#v", handle, command, message)
-	return unix.Syscall(unix.SYS_IOCTL, handle, command, uintptr(message))
+// ioCtlReturns issues a piControl command. If the command fails, the returned error includes
+// the driver's last message.
+func (g *gpioChip) ioCtlReturns(command uintptr, message unsafe.Pointer) (uintptr, error) {
+	g.logger.Debugf("Device: %v, Command: %#v, Message: %#v", g.dev, command, message)
+	ret, errno := g.image.ioCtl(command, message)
+	if errno != 0 {
+		return ret, g.withDriverMessage(errno)
+	}
+	return ret, nil
+}
+
+// lastDriverMessage returns the last error message reported by the piControl driver.
+func (g *gpioChip) lastDriverMessage() (string, error) {
+	var message [driverMessageLength]byte
+	//nolint:gosec
+	_, errno := g.image.ioCtl(uintptr(kbGetLastMessage), unsafe.Pointer(&message))
+	if errno != 0 {
+		return "", fmt.Errorf("failed to get the last message of %v: %w", g.dev, errno)
+	}
+	if end := bytes.IndexByte(message[:], 0); end >= 0 {
+		return strings.TrimSpace(string(message[:end])), nil
+	}
+	return strings.TrimSpace(string(message[:])), nil
+}
+
+// withDriverMessage adds the last message of the piControl driver to err, since the errno alone
+// rarely explains why a request failed.
+func (g *gpioChip) withDriverMessage(err error) error {
+	message, msgErr := g.lastDriverMessage()
+	if msgErr != nil || message == "" {
+		return err
+	}
+	return fmt.Errorf("%w (piControl: %s)", err, message)
+}
+
+// readAt reads from the process image, including the driver's last message in any error.
+// In the cached read mode the value comes from the latest snapshot of the process image.
+func (g *gpioChip) readAt(b []byte, address int64) (int, error) {
+	if g.cache != nil {
+		if n, cached, err := g.cache.readAt(b, address); cached {
+			return n, err
+		}
+	}
+	n, err := g.image.ReadAt(b, address)
+	if err != nil {
+		return n, g.withDriverMessage(err)
+	}
+	return n, nil
 }
 
 func (g *gpioChip) getBitValue(address int64, bitPosition uint8) (bool, error) {
 	b := make([]byte, 1)
-	n, err := g.fileHandle.ReadAt(b, address)
+	n, err := g.readAt(b, address)
 	g.logger.Debugf("Read %#v bytes", b)
-	if n != 1 {
-		return false, fmt.Errorf("expected 1 byte, got %#v", b)
-	}
 	if err != nil {
 		return false, err
 	}
+	if n != 1 {
+		return false, fmt.Errorf("expected 1 byte, got %#v", b)
+	}
 	if (b[0]>>bitPosition)&1 == 1 {
 		return true, nil
 	}
 	return false, nil
 }
 
+// writeValue writes an output. In the exported outputs mode the value goes to the application image.
 func (g *gpioChip) writeValue(address int64, b []byte) error {
+	if g.exported != nil {
+		g.logger.Debugf("Writing %#d to %v of the application image", b, address)
+		return g.exported.write(address, b)
+	}
+	return g.writeImage(address, b)
+}
+
+// setBitValue sets a single bit of an output. In the exported outputs mode the bit is set in the application image.
+func (g *gpioChip) setBitValue(address uint16, bit uint8, high bool) error {
+	if g.exported != nil {
+		g.logger.Debugf("Setting bit %d of %v of the application image to %v", bit, address, high)
+		return g.exported.setBit(address, bit, high)
+	}
+	return g.setImageBit(address, bit, high)
+}
+
+// setImageBit sets a single bit directly in the process image.
+func (g *gpioChip) setImageBit(address uint16, bit uint8, high bool) error {
+	val := uint8(0)
+	if high {
+		val = uint8(1)
+	}
+	command := SPIValue{i16uAddress: address, i8uBit: bit, i8uValue: val}
+	g.logger.Debugf("Command: %#v", command)
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	//nolint:gosec
+	if err := g.ioCtl(uintptr(kbSetValue), unsafe.Pointer(&command)); err != nil {
+		return err
+	}
+	g.lastWrite = int64(address) + 1
+	if g.cache != nil {
+		g.cache.updateBit(address, bit, high)
+	}
+	return nil
+}
+
+// writeImage writes directly to the process image.
+func (g *gpioChip) writeImage(address int64, b []byte) error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	return g.writeImageLocked(address, b)
+}
+
+// writeImageLocked writes directly to the process image while writeMu is held.
+func (g *gpioChip) writeImageLocked(address int64, b []byte) error {
 	g.logger.Debugf("Writing %#d to %v", b, address)
-	n, err := g.fileHandle.WriteAt(b, address)
+	n, err := g.image.WriteAt(b, address)
 	if err != nil {
-		return err
+		return g.withDriverMessage(err)
+	}
+	g.lastWrite = address + 1
+	if g.cache != nil {
+		g.cache.update(b, address)
 	}
 	g.logger.Debugf("Wrote %#d byte(s), n: %d", b, n)
 
@@ -157,7 +361,7 @@ func (g *gpioChip) writeValue(address int64, b []byte) error {
 }
 
 func (g *gpioChip) Close() error {
-	err := g.fileHandle.Close()
+	err := g.image.Close()
 	return err
 }
 
@@ -8,7 +8,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"unsafe"
 )
 
 const (
@@ -95,13 +94,18 @@ func (pin *gpioPin) getGpioAddress() uint16 {
 
 // Set sets the state of the pin on or off.
 func (pin *gpioPin) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	if !pin.initialized {
 		return errors.New("pin not initialized")
 	}
 
-	val := uint8(0)
-	if high {
-		val = uint8(1)
+	// digital inputs can only be written while inputs are being simulated
+	if pin.isDigitalInput() {
+		if err := pin.ControlChip.checkInputSimulation(pin.Name); err != nil {
+			return fmt.Errorf("cannot set pin state: %w", err)
+		}
+		return pin.ControlChip.setImageBit(pin.Address, pin.BitPosition, high)
 	}
 
 	// Error if we are not a pin that can support GPIO Outputs
@@ -124,18 +128,13 @@ func (pin *gpioPin) Set(ctx context.Context, high bool, extra map[string]interfa
 
 	// Because there could be a race in reading the byte with pin states, mutating,
 	// and writing back, we can leverage the ioctl command to modify 1 bit
-	command := SPIValue{i16uAddress: gpioAddress, i8uBit: gpioBit, i8uValue: val}
-	pin.ControlChip.logger.Debugf("Command: %#v", command)
-	//nolint:gosec
-	err := pin.ControlChip.ioCtl(uintptr(kbSetValue), unsafe.Pointer(&command))
-	if err != 0 {
-		return err
-	}
-	return nil
+	return pin.ControlChip.setBitValue(gpioAddress, gpioBit, high)
 }
 
 // Get gets the high/low state of the pin.
 func (pin *gpioPin) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	if !pin.initialized {
 		return false, errors.New("pin not initialized")
 	}
@@ -162,6 +161,8 @@ func (pin *gpioPin) Get(ctx context.Context, extra map[string]interface{}) (bool
 
 // PWM gets the pin's given duty cycle.
 func (pin *gpioPin) PWM(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	if !pin.initialized {
 		return 0, errors.New("pin not initialized")
 	}
@@ -180,14 +181,14 @@ func (pin *gpioPin) PWM(ctx context.Context, extra map[string]interface{}) (floa
 	}
 
 	b := make([]byte, 2)
-	n, err := pin.ControlChip.fileHandle.ReadAt(b, int64(pwmAddress))
+	n, err := pin.ControlChip.readAt(b, int64(pwmAddress))
 	pin.ControlChip.logger.Debugf("Read %#d bytes", b)
-	if n != 2 {
-		return 0, fmt.Errorf("expected 2 bytes, got %#v", b)
-	}
 	if err != nil {
 		return 0, err
 	}
+	if n != 2 {
+		return 0, fmt.Errorf("expected 2 bytes, got %#v", b)
+	}
 	b[1] = 0x00
 	val := binary.LittleEndian.Uint16(b)
 	if val > 100 {
@@ -198,6 +199,8 @@ func (pin *gpioPin) PWM(ctx context.Context, extra map[string]interface{}) (floa
 
 // SetPWM sets the pin to the given duty cycle.
 func (pin *gpioPin) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[string]interface{}) error {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	if !pin.initialized {
 		return errors.New("pin not initialized")
 	}
@@ -234,6 +237,8 @@ func (pin *gpioPin) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[
 
 // PWMFreq gets the PWM frequency of the pin.
 func (pin *gpioPin) PWMFreq(ctx context.Context, extra map[string]interface{}) (uint, error) {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	if !pin.initialized {
 		return 0, errors.New("pin not initialized")
 	}
@@ -243,7 +248,7 @@ func (pin *gpioPin) PWMFreq(ctx context.Context, extra map[string]interface{}) (
 
 	b := make([]byte, 1)
 	// all PWM pins use the same PWM frequency
-	n, err := pin.ControlChip.fileHandle.ReadAt(b, int64(pin.inputOffset+outputPWMFrequencyOffset))
+	n, err := pin.ControlChip.readAt(b, int64(pin.inputOffset+outputPWMFrequencyOffset))
 	if err != nil {
 		return 0, err
 	}
@@ -276,6 +281,8 @@ func stepSizeToFreq(step []byte) uint {
 
 // SetPWMFreq sets the given pin to the given PWM frequency. For the Rev-Pi this must be configured in PiCtory.
 func (pin *gpioPin) SetPWMFreq(ctx context.Context, freqHz uint, extra map[string]interface{}) error {
+	pin.ControlChip.configMu.RLock()
+	defer pin.ControlChip.configMu.RUnlock()
 	if !pin.initialized {
 		return errors.New("pin not initialized")
 	}
@@ -283,6 +290,11 @@ func (pin *gpioPin) SetPWMFreq(ctx context.Context, freqHz uint, extra map[strin
 	return errors.New("PWM Frequency must be set in PiCtory")
 }
 
+// bit pins at 0 or 1 + inputOffset.
+func (pin *gpioPin) isDigitalInput() bool {
+	return pin.Length == 1 && (pin.Address == pin.inputOffset || pin.Address == pin.inputOffset+1)
+}
+
 // pins at 70 or 71 + inputOffset.
 func (pin *gpioPin) isDigitalOutput() bool {
 	return pin.Address == pin.outputOffset || pin.Address == pin.outputOffset+1
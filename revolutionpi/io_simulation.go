@@ -0,0 +1,64 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+)
+
+const (
+	stopIOKey  = "stopIO"
+	startIOKey = "startIO"
+)
+
+// setIOStopped stops or restarts the IO communication between piControl and the modules.
+// While IO is stopped, piControl no longer refreshes the inputs in the process image, so they can be written by software.
+func (g *gpioChip) setIOStopped(stop bool) (bool, error) {
+	var request int32
+	if stop {
+		request = 1
+	}
+	//nolint:gosec
+	ret, err := g.ioCtlReturns(uintptr(kbStopIO), unsafe.Pointer(&request))
+	if err != nil {
+		return false, fmt.Errorf("failed to change IO communication state of %v: %w", g.dev, err)
+	}
+	// piControl returns the new state, 1 when IO communication is stopped
+	stopped := ret == 1
+	g.ioStopped.Store(stopped)
+	return stopped, nil
+}
+
+// checkInputSimulation returns an error unless software is allowed to write to the given input pin.
+func (g *gpioChip) checkInputSimulation(pinName string) error {
+	if !g.simulateInputs {
+		return fmt.Errorf("pin %s is an input, enable simulate_inputs to write to inputs", pinName)
+	}
+	if !g.ioStopped.Load() {
+		return fmt.Errorf("cannot write to input %s while IO communication is running, use %s first", pinName, stopIOKey)
+	}
+	return nil
+}
+
+// stopIO stops the IO communication so inputs can be simulated.
+func (b *revolutionPiBoard) stopIO(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	stopped, err := b.controlChip.setIOStopped(true)
+	if err != nil {
+		return err
+	}
+	resp["ioStopped"] = stopped
+	return nil
+}
+
+// startIO restarts the IO communication after it was stopped.
+func (b *revolutionPiBoard) startIO(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	stopped, err := b.controlChip.setIOStopped(false)
+	if err != nil {
+		return err
+	}
+	resp["ioStopped"] = stopped
+	return nil
+}
@@ -12,6 +12,9 @@ func ioctlAddress(v int) int {
 // The hex output received when a board is not connected.
 const piControlNotConnected = 0x8000
 
+// The length of the buffer used with kbGetLastMessage.
+const driverMessageLength = 256
+
 // leaving these variables in as potential options to interface with the Revolution Pi
 //
 //nolint:unused
@@ -56,6 +59,25 @@ type SPIValue struct {
 	i8uValue    uint8  // Value: 0/1 for bit access, whole byte otherwise
 }
 
+// SDIOResetCounter is the struct representing the counters to reset on a DIO module.
+// use kbDIOResetCounter with ioctl to set the counters or encoders to 0.
+type SDIOResetCounter struct {
+	i8uAddress   uint8  // Address of module in current configuration
+	i16uBitfield uint16 // bitfield, if bit n is 1, reset counter/encoder on input n
+}
+
+// SAIOCalibrate is the struct representing a calibration request for the channels of an AIO module.
+// use kbAIOCalibrate with ioctl to start a calibration, apply a reference point or commit the calibration.
+// The fields follow SAIOCalibrate in piControl.h, where the mode is named i8uCallibrationMode and the points
+// i16sX and i16sY. The layout has not been checked against a released piControl.h, see aioCalibrateMode.
+type SAIOCalibrate struct {
+	i8uAddress  uint8 // Address of module in current configuration
+	i8uMode     uint8 // calibration step, one of the aioCalibrateMode values
+	i8uChannels uint8 // bitfield, bits 0-3 select analog inputs 1-4 and bits 4-5 select analog outputs 1-2
+	i16sXVal    int16 // value measured by the module at the reference point
+	i16sYVal    int16 // expected value at the reference point
+}
+
 // SDeviceInfo is a struct representing the devices being used by the Revolution Pi module.
 // use kbGetDeviceInfoList with ioctl to populate a list of these
 //
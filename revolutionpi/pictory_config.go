@@ -0,0 +1,137 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPiCtoryConfig is where PiCtory stores the configuration loaded by piControl.
+const defaultPiCtoryConfig = "/etc/revpi/config.rsc"
+
+// piCtoryConfig is the subset of a PiCtory config.rsc export used by this module.
+type piCtoryConfig struct {
+	Devices []piCtoryDevice `json:"Devices"`
+}
+
+// piCtoryDevice is a module in a PiCtory configuration.
+// Variables are stored by index, with each entry being an array of
+// [name, default value, length in bits, offset, exported, sort order, comment, bit position].
+type piCtoryDevice struct {
+	Name        string                       `json:"name"`
+	ProductType rscNumber                    `json:"productType"`
+	Position    rscNumber                    `json:"position"`
+	Offset      rscNumber                    `json:"offset"`
+	Inputs      map[string][]json.RawMessage `json:"inp"`
+	Outputs     map[string][]json.RawMessage `json:"out"`
+	Memory      map[string][]json.RawMessage `json:"mem"`
+}
+
+// piCtoryVariable is a variable of a PiCtory device. The offset is relative to the first byte of the device.
+type piCtoryVariable struct {
+	name         string
+	defaultValue int64
+	length       uint16
+	offset       uint16
+	bit          uint8
+}
+
+// rscNumber is a number in a config.rsc file. PiCtory stores most numbers as strings.
+type rscNumber int64
+
+func (n *rscNumber) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		f, floatErr := strconv.ParseFloat(s, 64)
+		if floatErr != nil {
+			return fmt.Errorf("invalid number %s: %w", b, err)
+		}
+		v = int64(f)
+	}
+	*n = rscNumber(v)
+	return nil
+}
+
+// readPiCtoryConfig reads a PiCtory config.rsc file.
+func readPiCtoryConfig(path string) (*piCtoryConfig, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PiCtory config %v: %w", path, err)
+	}
+	var cfg piCtoryConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse PiCtory config %v: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// variables parses the entries of one section of the device, sorted by their index.
+func (dev *piCtoryDevice) variables(section map[string][]json.RawMessage) ([]piCtoryVariable, error) {
+	keys := make([]int, 0, len(section))
+	for k := range section {
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("device %s has an invalid variable index %q", dev.Name, k)
+		}
+		keys = append(keys, i)
+	}
+	sort.Ints(keys)
+
+	vars := make([]piCtoryVariable, 0, len(keys))
+	for _, k := range keys {
+		entry := section[strconv.Itoa(k)]
+		if len(entry) < 4 {
+			return nil, fmt.Errorf("device %s variable %d has %d fields, expected at least 4", dev.Name, k, len(entry))
+		}
+		var name string
+		var defaultValue, length, offset, bit rscNumber
+		if err := json.Unmarshal(entry[0], &name); err != nil {
+			return nil, fmt.Errorf("device %s variable %d has an invalid name: %w", dev.Name, k, err)
+		}
+		for i, field := range []*rscNumber{&defaultValue, &length, &offset} {
+			if err := json.Unmarshal(entry[i+1], field); err != nil {
+				return nil, fmt.Errorf("device %s variable %s: %w", dev.Name, name, err)
+			}
+		}
+		if len(entry) > 7 {
+			if err := json.Unmarshal(entry[7], &bit); err != nil {
+				return nil, fmt.Errorf("device %s variable %s: %w", dev.Name, name, err)
+			}
+		}
+		v := piCtoryVariable{name: name, defaultValue: int64(defaultValue), length: uint16(length), offset: uint16(offset)}
+		// bit variables may address any bit of a word, so normalize them to a byte and bit position
+		if v.length == 1 {
+			v.offset += uint16(bit) / 8
+			v.bit = uint8(bit % 8)
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
+
+// variablesEnd returns the offset of the first byte after the given variables.
+func variablesEnd(vars []piCtoryVariable) uint16 {
+	var last uint16
+	for _, v := range vars {
+		size := v.length / 8
+		if size == 0 {
+			size = 1
+		}
+		if v.offset+size > last {
+			last = v.offset + size
+		}
+	}
+	return last
+}
@@ -0,0 +1,173 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// pinKind is the role of a variable in the process image, based on where it is located in its module.
+type pinKind int
+
+const (
+	pinKindUnsupported pinKind = iota
+	pinKindDigitalInput
+	pinKindDigitalOutput
+	pinKindPWM
+	pinKindCounter
+	pinKindAnalogInput
+	pinKindAnalogOutput
+)
+
+func (k pinKind) String() string {
+	switch k {
+	case pinKindDigitalInput:
+		return "digital input"
+	case pinKindDigitalOutput:
+		return "digital output"
+	case pinKindPWM:
+		return "pwm"
+	case pinKindCounter:
+		return "counter"
+	case pinKindAnalogInput:
+		return "analog input"
+	case pinKindAnalogOutput:
+		return "analog output"
+	default:
+		return "unsupported"
+	}
+}
+
+// pinNames are the usable pins of the board, grouped by the board API that serves them.
+type pinNames struct {
+	analogs    []string
+	gpios      []string
+	interrupts []string
+}
+
+// enumeratePins discovers every variable that can be used through the board APIs. Pins that are
+// not usable with the current configuration, such as disabled analog outputs or counters in encoder mode,
+// are left out.
+func (g *gpioChip) enumeratePins() pinNames {
+	names := pinNames{analogs: []string{}, gpios: []string{}, interrupts: []string{}}
+	for _, name := range g.variableNames() {
+		pin := SPIVariable{strVarName: char32(name)}
+		if err := g.mapNameToAddress(&pin); err != nil {
+			continue
+		}
+		kind := g.classifyPin(pin)
+		g.logger.Debugf("pin %s is a %v", name, kind)
+		switch kind {
+		case pinKindDigitalInput, pinKindDigitalOutput:
+			names.gpios = append(names.gpios, name)
+		case pinKindPWM:
+			// PWM pins can only be used while PWM is enabled in PiCtory
+			if gpio, err := g.GetGPIOPin(name); err == nil && gpio.pwmMode {
+				names.gpios = append(names.gpios, name)
+			}
+		case pinKindCounter:
+			if _, err := initializeDigitalInterrupt(pin, g, false); err == nil {
+				names.interrupts = append(names.interrupts, name)
+			}
+		case pinKindAnalogInput, pinKindAnalogOutput:
+			if _, err := initializeAnalogPin(pin, g); err == nil {
+				names.analogs = append(names.analogs, name)
+			}
+		case pinKindUnsupported:
+		}
+	}
+	g.logger.Infof("found %d analog, %d gpio and %d digital interrupt pins",
+		len(names.analogs), len(names.gpios), len(names.interrupts))
+	return names
+}
+
+// variableNames returns the names of every input and output variable in the PiCtory config.
+// If the config cannot be read, the default variable names of each DIO and AIO module in the device list are used,
+// which only finds the pins of modules that PiCtory did not have to rename.
+func (g *gpioChip) variableNames() []string {
+	if g.configPath != "" {
+		names, err := piCtoryVariableNames(g.configPath)
+		if err == nil {
+			return names
+		}
+		g.logger.Warnf("unable to read variable names from the PiCtory config, using the device list instead: %v", err)
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	add := func(format string, count int) {
+		for i := 1; i <= count; i++ {
+			name := fmt.Sprintf(format, i)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for range g.dioDevices {
+		add("I_%d", 16)
+		add("O_%d", 16)
+		add("PWM_%d", 16)
+		add("Counter_%d", 16)
+	}
+	for range g.aioDevices {
+		add("InputValue_%d", 4)
+		add("RTDValue_%d", 2)
+		add("OutputValue_%d", 2)
+	}
+	return names
+}
+
+func piCtoryVariableNames(path string) ([]string, error) {
+	cfg, err := readPiCtoryConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for i := range cfg.Devices {
+		dev := &cfg.Devices[i]
+		for _, section := range []map[string][]json.RawMessage{dev.Inputs, dev.Outputs} {
+			vars, err := dev.variables(section)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range vars {
+				names = append(names, v.name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// classifyPin determines the role of a variable based on the module it belongs to and its address.
+func (g *gpioChip) classifyPin(pin SPIVariable) pinKind {
+	if dio, err := findDevice(pin.i16uAddress, g.dioDevices); err == nil {
+		gpio := gpioPin{
+			Address: pin.i16uAddress, Length: pin.i16uLength,
+			outputOffset: dio.i16uOutputOffset, inputOffset: dio.i16uInputOffset,
+		}
+		switch {
+		case gpio.isDigitalOutput() && pin.i16uLength == 1:
+			return pinKindDigitalOutput
+		case gpio.isOutputPWM():
+			return pinKindPWM
+		case gpio.isInputCounter():
+			return pinKindCounter
+		case gpio.isDigitalInput():
+			return pinKindDigitalInput
+		}
+		return pinKindUnsupported
+	}
+	if aio, err := findDevice(pin.i16uAddress, g.aioDevices); err == nil {
+		analog := analogPin{Address: pin.i16uAddress, outputOffset: aio.i16uOutputOffset, inputOffset: aio.i16uInputOffset}
+		switch {
+		case analog.isAnalogInput(), analog.isRTDInput():
+			return pinKindAnalogInput
+		case analog.isAnalogOutput():
+			return pinKindAnalogOutput
+		}
+	}
+	return pinKindUnsupported
+}
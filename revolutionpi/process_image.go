@@ -0,0 +1,87 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	backendPiControl = "picontrol"
+	backendSimulated = "simulated"
+)
+
+// processImage is the backend that owns the piControl process image. Reads and writes
+// address the process image directly, and ioCtl issues one of the kb* commands from io_util.go.
+// The default backend is the piControl device, while simulatedImage emulates the driver in memory.
+type processImage interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	// ioCtl issues a piControl command, returning the driver's return value and any errno.
+	ioCtl(command uintptr, message unsafe.Pointer) (uintptr, syscall.Errno)
+}
+
+// piControlImage is the processImage backed by the piControl character device.
+type piControlImage struct {
+	fileHandle *os.File
+}
+
+func openPiControl(devPath string) (*piControlImage, error) {
+	fd, err := os.OpenFile(devPath, os.O_RDWR, fs.FileMode(os.O_RDWR))
+	if err != nil {
+		return nil, fmt.Errorf("open chip %v failed: %w", devPath, err)
+	}
+	return &piControlImage{fileHandle: fd}, nil
+}
+
+func (p *piControlImage) ReadAt(b []byte, off int64) (int, error) {
+	return p.fileHandle.ReadAt(b, off)
+}
+
+func (p *piControlImage) WriteAt(b []byte, off int64) (int, error) {
+	return p.fileHandle.WriteAt(b, off)
+}
+
+func (p *piControlImage) ioCtl(command uintptr, message unsafe.Pointer) (uintptr, syscall.Errno) {
+	ret, _, err := unix.Syscall(unix.SYS_IOCTL, p.fileHandle.Fd(), command, uintptr(message))
+	return ret, err
+}
+
+func (p *piControlImage) Close() error {
+	return p.fileHandle.Close()
+}
+
+// openProcessImage opens the process image for the requested backend, defaulting to the piControl device.
+// The simulated backend is built from the PiCtory config at configPath when one is given.
+func openProcessImage(backend, configPath string) (string, processImage, error) {
+	switch backend {
+	case "", backendPiControl:
+		devPath := filepath.Clean(filepath.Join("/dev", "piControl0"))
+		image, err := openPiControl(devPath)
+		if err != nil {
+			return "", nil, err
+		}
+		return devPath, image, nil
+	case backendSimulated:
+		if configPath == "" {
+			return backendSimulated, newSimulatedImage(), nil
+		}
+		image, err := newSimulatedImageFromConfig(configPath)
+		if err != nil {
+			return "", nil, err
+		}
+		return backendSimulated, image, nil
+	default:
+		return "", nil, validateBackend(backend)
+	}
+}
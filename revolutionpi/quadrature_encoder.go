@@ -0,0 +1,270 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/encoder"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+const (
+	// defaultQuadraturePollInterval is how often the inputs of a quadrature encoder are sampled when neither an
+	// interval is configured nor the IO cycle can be read.
+	defaultQuadraturePollInterval = time.Millisecond
+
+	quadratureErrorsKey = "errors"
+	// quadratureCountsPerPulse is the number of counts per pulse, every edge of both channels is counted.
+	quadratureCountsPerPulse = 4
+	// missedTransition marks a change of both channels between two samples in quadratureSteps.
+	missedTransition = 2
+)
+
+// QuadratureEncoderModel is the model triplet for the rev-pi software quadrature encoder.
+var QuadratureEncoderModel = resource.NewModel("viam-labs", "kunbus", "revolutionpi-quadrature-encoder")
+
+// QuadratureEncoderConfig is the config for the rev-pi software quadrature encoder.
+type QuadratureEncoderConfig struct {
+	// Board is the revolution pi board the encoder is connected to.
+	Board string `json:"board"`
+	// APin and BPin are the digital inputs of the A and B channels.
+	APin string `json:"a_pin"`
+	BPin string `json:"b_pin"`
+	// TicksPerRotation is the number of pulses per rotation of the encoder. Setting it enables positions in degrees.
+	TicksPerRotation int `json:"ticks_per_rotation,omitempty"`
+	// PollIntervalUs is how often the inputs are sampled, half the piBridge IO cycle by default. The inputs only
+	// change once per IO cycle, so the encoder misses transitions if both channels change within one cycle.
+	PollIntervalUs int `json:"poll_interval_us,omitempty"`
+}
+
+func init() {
+	resource.RegisterComponent(
+		encoder.API,
+		QuadratureEncoderModel,
+		resource.Registration[encoder.Encoder, *QuadratureEncoderConfig]{Constructor: newQuadratureEncoder})
+}
+
+// Validate validates the QuadratureEncoderConfig.
+func (cfg *QuadratureEncoderConfig) Validate(path string) ([]string, error) {
+	if cfg.Board == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "board")
+	}
+	if cfg.APin == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "a_pin")
+	}
+	if cfg.BPin == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "b_pin")
+	}
+	if cfg.APin == cfg.BPin {
+		return nil, resource.NewConfigValidationError(path, errors.New("a_pin and b_pin must be different inputs"))
+	}
+	if cfg.TicksPerRotation < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("ticks_per_rotation cannot be negative"))
+	}
+	if cfg.PollIntervalUs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("poll_interval_us cannot be negative"))
+	}
+	return []string{cfg.Board}, nil
+}
+
+// quadratureSteps maps the previous and current state of the channels, A in bit 1 and B in bit 0, to the
+// change of the position. A leading B counts up. Both channels changing at once is a missed transition,
+// because the direction cannot be known.
+var quadratureSteps = [4][4]int8{
+	{0, -1, 1, 2},
+	{1, 0, 2, -1},
+	{-1, 2, 0, 1},
+	{2, 1, -1, 0},
+}
+
+// quadratureEncoder decodes the A and B channels of an incremental encoder wired to two digital inputs.
+type quadratureEncoder struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+	a, b   *gpioPin
+	chip   *gpioChip
+	// ticksPerRotation is the number of counts per rotation, 0 if it is not known
+	ticksPerRotation float64
+
+	mu       sync.Mutex
+	state    uint8
+	position int64
+	// errorCount is the number of missed transitions
+	errorCount int64
+
+	cancelCtx               context.Context
+	cancelFunc              func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+func newQuadratureEncoder(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (encoder.Encoder, error) {
+	newConf, err := resource.NativeConfig[*QuadratureEncoderConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	revPi, err := revolutionPiBoardFromDependencies(deps, newConf.Board)
+	if err != nil {
+		return nil, err
+	}
+	a, err := revPi.digitalInputPin(newConf.APin)
+	if err != nil {
+		return nil, err
+	}
+	b, err := revPi.digitalInputPin(newConf.BPin)
+	if err != nil {
+		return nil, err
+	}
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	enc := &quadratureEncoder{
+		Named:            conf.ResourceName().AsNamed(),
+		logger:           logger,
+		a:                a,
+		b:                b,
+		chip:             a.ControlChip.acquire(),
+		ticksPerRotation: float64(newConf.TicksPerRotation * quadratureCountsPerPulse),
+		cancelCtx:        cancelCtx,
+		cancelFunc:       cancelFunc,
+	}
+	enc.state, err = enc.readState()
+	if err != nil {
+		cancelFunc()
+		return nil, multierr.Combine(err, enc.chip.release())
+	}
+	interval := time.Duration(newConf.PollIntervalUs) * time.Microsecond
+	if interval == 0 {
+		interval = defaultQuadraturePollInterval
+		// the inputs only change once per IO cycle, sampling twice per cycle sees every change
+		if cycle, err := enc.chip.ioCycle(); err != nil {
+			logger.Debugf("failed to read the IO cycle, sampling every %v: %v", interval, err)
+		} else if cycle > 0 {
+			interval = cycle / 2
+		}
+	}
+	enc.startPoller(interval)
+	return enc, nil
+}
+
+// ioCycle returns the duration of the last piBridge IO cycle from RevPiIOCycle, 0 if it is not known yet.
+func (g *gpioChip) ioCycle() (time.Duration, error) {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	pin := SPIVariable{strVarName: char32("RevPiIOCycle")}
+	if err := g.mapNameToAddress(&pin); err != nil {
+		return 0, err
+	}
+	cycle := make([]byte, 1)
+	if _, err := g.image.ReadAt(cycle, int64(pin.i16uAddress)); err != nil {
+		return 0, g.withDriverMessage(err)
+	}
+	return time.Duration(cycle[0]) * time.Millisecond, nil
+}
+
+// readState reads both channels with a single read of the input bytes, bypassing the cached reads,
+// and returns A in bit 1 and B in bit 0.
+func (enc *quadratureEncoder) readState() (uint8, error) {
+	enc.chip.configMu.RLock()
+	defer enc.chip.configMu.RUnlock()
+	if !enc.a.initialized || !enc.b.initialized {
+		return 0, errors.New("quadrature encoder pins are no longer part of the configuration")
+	}
+	start := min(enc.a.Address, enc.b.Address)
+	end := max(enc.a.Address, enc.b.Address)
+	inputs := make([]byte, end-start+1)
+	if _, err := enc.chip.image.ReadAt(inputs, int64(start)); err != nil {
+		return 0, enc.chip.withDriverMessage(err)
+	}
+	a := inputs[enc.a.Address-start] >> enc.a.BitPosition & 1
+	b := inputs[enc.b.Address-start] >> enc.b.BitPosition & 1
+	return a<<1 | b, nil
+}
+
+// update decodes the transition to a new state of the channels.
+func (enc *quadratureEncoder) update(state uint8) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	switch step := quadratureSteps[enc.state][state]; step {
+	case missedTransition:
+		enc.errorCount++
+	default:
+		enc.position += int64(step)
+	}
+	enc.state = state
+}
+
+// startPoller samples the channels from a background worker until the encoder closes.
+func (enc *quadratureEncoder) startPoller(interval time.Duration) {
+	enc.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-enc.cancelCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			state, err := enc.readState()
+			if err != nil {
+				enc.logger.Debugf("failed to sample quadrature encoder: %v", err)
+				continue
+			}
+			enc.update(state)
+		}
+	}, enc.activeBackgroundWorkers.Done)
+	enc.logger.Infof("sampling quadrature encoder every %v", interval)
+}
+
+func (enc *quadratureEncoder) Position(ctx context.Context, positionType encoder.PositionType,
+	extra map[string]interface{},
+) (float64, encoder.PositionType, error) {
+	enc.mu.Lock()
+	position := enc.position
+	enc.mu.Unlock()
+	return toPositionType(float64(position), enc.ticksPerRotation, positionType)
+}
+
+// ResetPosition sets the position and the error count back to 0.
+func (enc *quadratureEncoder) ResetPosition(ctx context.Context, extra map[string]interface{}) error {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	enc.position = 0
+	enc.errorCount = 0
+	return nil
+}
+
+func (enc *quadratureEncoder) Properties(ctx context.Context, extra map[string]interface{}) (encoder.Properties, error) {
+	return encoder.Properties{TicksCountSupported: true, AngleDegreesSupported: enc.ticksPerRotation > 0}, nil
+}
+
+// DoCommand supports {"errors": true}, which returns the number of missed transitions since the last reset.
+func (enc *quadratureEncoder) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return runCommands(ctx, []boardCommand{{key: quadratureErrorsKey, handler: enc.missedTransitions}}, req)
+}
+
+// missedTransitions returns the number of transitions that were missed because both channels changed between two samples.
+func (enc *quadratureEncoder) missedTransitions(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	resp[quadratureErrorsKey] = enc.errorCount
+	return nil
+}
+
+func (enc *quadratureEncoder) Close(ctx context.Context) error {
+	enc.cancelFunc()
+	enc.activeBackgroundWorkers.Wait()
+	return enc.chip.release()
+}
@@ -0,0 +1,148 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/utils"
+)
+
+const (
+	// staleSnapshotCycles is the number of missed refreshes after which cached reads fail instead of returning old values.
+	staleSnapshotCycles = 10
+	// minStaleSnapshotAge keeps short cycles from failing reads when the refresh is briefly delayed.
+	minStaleSnapshotAge = time.Second
+)
+
+// readCache is a snapshot of the used range of the process image. While it is enabled, reads are served
+// from the snapshot, which a background worker refreshes once per cycle with a single read.
+type readCache struct {
+	mu        sync.RWMutex
+	cycle     time.Duration
+	start     int64
+	data      []byte
+	timestamp time.Time
+}
+
+// enableReadCache switches the chip to cached reads, refreshed every cycle.
+func (g *gpioChip) enableReadCache(cycle time.Duration) error {
+	cache := &readCache{cycle: cycle}
+	if err := g.resizeReadCache(cache); err != nil {
+		return err
+	}
+	if err := g.refreshReadCache(cache); err != nil {
+		return err
+	}
+	g.cache = cache
+	return nil
+}
+
+// resizeReadCache sets the range of the snapshot to the part of the process image used by the devices.
+func (g *gpioChip) resizeReadCache(cache *readCache) error {
+	deviceInfoList, err := g.deviceInfoList()
+	if err != nil {
+		return err
+	}
+	start, end := int64(processImageSize), int64(0)
+	for _, dev := range deviceInfoList {
+		devEnd := int64(dev.i16uBaseOffset + dev.i16uInputLength + dev.i16uOutputLength + dev.i16uConfigLength)
+		start = min(start, int64(dev.i16uBaseOffset))
+		end = max(end, devEnd)
+	}
+	if end <= start {
+		start, end = 0, 0
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.start = start
+	cache.data = make([]byte, end-start)
+	cache.timestamp = time.Time{}
+	return nil
+}
+
+// refreshReadCache reads the used range of the process image into the snapshot.
+func (g *gpioChip) refreshReadCache(cache *readCache) error {
+	cache.mu.RLock()
+	data := make([]byte, len(cache.data))
+	start := cache.start
+	cache.mu.RUnlock()
+
+	if _, err := g.image.ReadAt(data, start); err != nil {
+		return g.withDriverMessage(err)
+	}
+	now := time.Now()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	// the range may have changed while reading if the configuration was reloaded
+	if cache.start == start && len(cache.data) == len(data) {
+		cache.data = data
+		cache.timestamp = now
+	}
+	return nil
+}
+
+// readAt copies from the snapshot. It returns false if the requested range is not part of the snapshot.
+func (c *readCache) readAt(b []byte, address int64) (int, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	offset := address - c.start
+	if offset < 0 || offset+int64(len(b)) > int64(len(c.data)) {
+		return 0, false, nil
+	}
+	if age := time.Since(c.timestamp); age > max(staleSnapshotCycles*c.cycle, minStaleSnapshotAge) {
+		return 0, true, fmt.Errorf("process image snapshot is stale, last refreshed %v ago", age)
+	}
+	return copy(b, c.data[offset:]), true, nil
+}
+
+// update writes b into the snapshot, so a value written by the board can be read back before the next refresh.
+func (c *readCache) update(b []byte, address int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	offset := address - c.start
+	if offset < 0 || offset+int64(len(b)) > int64(len(c.data)) {
+		return
+	}
+	copy(c.data[offset:], b)
+}
+
+// updateBit sets a single bit of the snapshot.
+func (c *readCache) updateBit(address uint16, bit uint8, high bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	offset := int64(address) - c.start
+	if offset < 0 || offset >= int64(len(c.data)) {
+		return
+	}
+	if high {
+		c.data[offset] |= 1 << bit
+	} else {
+		c.data[offset] &^= 1 << bit
+	}
+}
+
+// snapshotTime returns when the snapshot was last refreshed.
+func (c *readCache) snapshotTime() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.timestamp
+}
+
+// startReadCache refreshes the snapshot from a background worker once per cycle until the board closes.
+func (b *revolutionPiBoard) startReadCache() {
+	cache := b.controlChip.cache
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for utils.SelectContextOrWait(b.cancelCtx, cache.cycle) {
+			if err := b.controlChip.refreshReadCache(cache); err != nil {
+				b.logger.Warnf("failed to refresh process image snapshot: %v", err)
+			}
+		}
+	}, b.activeBackgroundWorkers.Done)
+	b.logger.Infof("reading the process image every %v", cache.cycle)
+}
@@ -0,0 +1,147 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeDIOConfig writes a PiCtory config.rsc with the given number of DIO modules and returns its path.
+// The variables of every module after the first get the module number as suffix, as PiCtory does.
+func writeDIOConfig(tb testing.TB, modules int) string {
+	tb.Helper()
+	type device struct {
+		Name        string                       `json:"name"`
+		ProductType string                       `json:"productType"`
+		Position    string                       `json:"position"`
+		Offset      int                          `json:"offset"`
+		Inputs      map[string][]json.RawMessage `json:"inp"`
+		Outputs     map[string][]json.RawMessage `json:"out"`
+		Memory      map[string][]json.RawMessage `json:"mem"`
+	}
+	field := func(v interface{}) json.RawMessage {
+		data, err := json.Marshal(v)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return data
+	}
+	var devices []device
+	for m := 0; m < modules; m++ {
+		dev := device{
+			Name:        "RevPi DIO",
+			ProductType: "96",
+			Position:    strconv.Itoa(32 + m),
+			Offset:      m * (dioInputLength + dioOutputLength + dioConfigLength),
+			Inputs:      map[string][]json.RawMessage{},
+			Outputs:     map[string][]json.RawMessage{},
+			Memory:      map[string][]json.RawMessage{},
+		}
+		for i, v := range simulatedDIOVariables() {
+			name := v.name
+			if m > 0 {
+				name = fmt.Sprintf("%s_%d", v.name, m+1)
+			}
+			section := dev.Memory
+			switch {
+			case v.offset < dioInputLength:
+				section = dev.Inputs
+			case v.offset < dioInputLength+dioOutputLength:
+				section = dev.Outputs
+			}
+			section[strconv.Itoa(i)] = []json.RawMessage{
+				field(name), field(strconv.FormatInt(v.initial, 10)), field(strconv.Itoa(int(v.length))),
+				field(strconv.Itoa(int(v.offset))), field(true), field("0000"), field(""), field(strconv.Itoa(int(v.bit))),
+			}
+		}
+		devices = append(devices, dev)
+	}
+	data, err := json.Marshal(map[string]interface{}{"Devices": devices})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(tb.TempDir(), "config.rsc")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkGetInputs compares reading 64 digital inputs from the process image with reading them from the snapshot.
+func BenchmarkGetInputs(b *testing.B) {
+	const inputs = 64
+	for _, readCycleMs := range []int{0, 5} {
+		b.Run(fmt.Sprintf("read_cycle_ms=%d", readCycleMs), func(b *testing.B) {
+			ctx := context.Background()
+			revPi := newSimulatedBoard(b, &Config{PiCtoryConfig: writeDIOConfig(b, 5), ReadCycleMs: readCycleMs})
+			pins := make([]interface {
+				Get(context.Context, map[string]interface{}) (bool, error)
+			}, 0, inputs)
+			for m := 0; len(pins) < inputs; m++ {
+				for i := 1; i <= 14 && len(pins) < inputs; i++ {
+					name := fmt.Sprintf("I_%d", i)
+					if m > 0 {
+						name = fmt.Sprintf("%s_%d", name, m+1)
+					}
+					pin, err := revPi.GPIOPinByName(name)
+					if err != nil {
+						b.Fatal(err)
+					}
+					pins = append(pins, pin)
+				}
+			}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for _, pin := range pins {
+					if _, err := pin.Get(ctx, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReadCacheServesWrites(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{ReadCycleMs: 5})
+	pin, err := b.GPIOPinByName("O_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pin.Set(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	// writes by the board are visible before the next refresh of the snapshot
+	if high, err := pin.Get(ctx, nil); err != nil || !high {
+		t.Errorf("O_1 read %v, %v right after setting it high", high, err)
+	}
+
+	// changes by piControl are visible after the next refresh
+	writeVariable(t, b.controlChip, "I_1", []byte{1})
+	in, err := b.GPIOPinByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		high, err := in.Get(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if high {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the snapshot was not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const reloadConfigKey = "reloadConfig"
+
+// resetDriver makes piControl reload the PiCtory configuration and restart IO communication.
+func (g *gpioChip) resetDriver() error {
+	g.ownReset.Store(time.Now().UnixNano())
+	err := g.ioCtl(uintptr(kbReset), nil)
+	if err != nil {
+		g.ownReset.Store(0)
+		return fmt.Errorf("failed to reset %v: %w", g.dev, err)
+	}
+	g.ioStopped.Store(false)
+	g.noteDriverReset()
+	return nil
+}
+
+// reloadConfig re-reads the device list and re-initializes every pin handed out by the board, so cached
+// offsets, PWM modes and analog ranges match the new configuration. Pins that are no longer valid stop working
+// until they are requested again. If resetDriver is set, piControl is reset first so it reloads the PiCtory configuration.
+func (b *revolutionPiBoard) reloadConfig(resetDriver bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g := b.controlChip
+	g.configMu.Lock()
+	defer g.configMu.Unlock()
+
+	if resetDriver {
+		if err := g.resetDriver(); err != nil {
+			return err
+		}
+	}
+	// the device list reports misconfigured devices, but the remaining devices can still be used
+	deviceErrs := g.showDeviceList()
+	if g.cache != nil {
+		// the pins below are read from the snapshot, so it has to cover the new configuration first
+		if err := g.resizeReadCache(g.cache); err != nil {
+			deviceErrs = multierr.Combine(deviceErrs, err)
+		} else if err := g.refreshReadCache(g.cache); err != nil {
+			deviceErrs = multierr.Combine(deviceErrs, err)
+		}
+	}
+
+	for name, pin := range b.gpioPins {
+		fresh, err := g.GetGPIOPin(name)
+		if err != nil {
+			b.logger.Warnf("pin %s is no longer available after reloading the configuration: %v", name, err)
+			pin.initialized = false
+			continue
+		}
+		*pin = *fresh
+	}
+	for name, pin := range b.analogPins {
+		fresh, err := g.GetAnalogPin(name)
+		if err != nil {
+			b.logger.Warnf("analog %s is no longer available after reloading the configuration: %v", name, err)
+			pin.initialized = false
+			continue
+		}
+		*pin = *fresh
+	}
+	for name, pin := range b.interruptPins {
+		fresh, err := g.GetDigitalInterrupt(name)
+		if err != nil {
+			b.logger.Warnf("digital interrupt %s is no longer available after reloading the configuration: %v", name, err)
+			pin.enabled = false
+			continue
+		}
+		*pin = *fresh
+	}
+	for name, pin := range b.encoderPins {
+		fresh, err := g.GetEncoder(name)
+		if err != nil {
+			b.logger.Warnf("encoder %s is no longer available after reloading the configuration: %v", name, err)
+			pin.enabled = false
+			continue
+		}
+		*pin = *fresh
+	}
+
+	if g.exported != nil {
+		// the exported outputs may have moved in the new configuration
+		if err := g.resolveExportedOutputs(g.exported); err != nil {
+			b.logger.Warnf("exported outputs are not all available after reloading the configuration: %v", err)
+		}
+	}
+
+	pins := g.enumeratePins()
+	b.AnalogReaders = pins.analogs
+	b.GPIONames = pins.gpios
+	b.DigitalInterrupts = append(pins.interrupts, b.softwareInterruptNames...)
+	b.logger.Info("configuration reloaded")
+	return deviceErrs
+}
+
+// reloadConfigCommand resets piControl and re-initializes the board.
+func (b *revolutionPiBoard) reloadConfigCommand(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	if err := b.reloadConfig(true); err != nil {
+		return err
+	}
+	resp[reloadConfigKey] = true
+	return nil
+}
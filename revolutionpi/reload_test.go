@@ -0,0 +1,37 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReloadRevivesLostPins(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+	writeVariable(t, b.controlChip, "InputMode_1", []byte{inputModeCounterFalling})
+	interrupt, err := b.DigitalInterruptByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeVariable(t, b.controlChip, "InputMode_1", []byte{0})
+	if err := b.reloadConfig(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interrupt.Value(ctx, nil); err == nil {
+		t.Error("expected an error reading a counter that is no longer configured")
+	}
+	if _, err := b.DigitalInterruptByName("I_1"); err == nil {
+		t.Error("expected an error requesting a counter that is no longer configured")
+	}
+
+	writeVariable(t, b.controlChip, "InputMode_1", []byte{inputModeCounterFalling})
+	if _, err := b.DigitalInterruptByName("I_1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interrupt.Value(ctx, nil); err != nil {
+		t.Errorf("the counter still fails after it was requested again: %v", err)
+	}
+}
@@ -6,12 +6,10 @@ package revolutionpi
 import (
 	"context"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
+	"go.uber.org/multierr"
 	pb "go.viam.com/api/component/board/v1"
 	"go.viam.com/rdk/components/board"
 	"go.viam.com/rdk/grpc"
@@ -21,16 +19,43 @@ import (
 
 const (
 	readParameterKey = "readParameter"
+	resetCounterKey  = "resetCounter"
+	lastMessageKey   = "lastDriverMessage"
+	snapshotTimeKey  = "snapshotTime"
 )
 
 type revolutionPiBoard struct {
 	resource.Named
-	resource.TriviallyReconfigurable
+	resource.AlwaysRebuild
 
-	mu            sync.RWMutex
-	logger        logging.Logger
-	AnalogReaders []string
-	GPIONames     []string
+	mu                sync.RWMutex
+	logger            logging.Logger
+	AnalogReaders     []string
+	GPIONames         []string
+	DigitalInterrupts []string
+
+	// pins handed out by the board, kept so they can be re-initialized when the configuration is reloaded
+	gpioPins      map[string]*gpioPin
+	analogPins    map[string]*analogPin
+	interruptPins map[string]*counterPin
+	// counters used by encoder components that depend on the board
+	encoderPins map[string]*counterPin
+	// the listener of the board's piControl device, which keeps the event history
+	listener *eventListener
+
+	// AIO calibrations in progress and the last committed calibration of each channel
+	calibrations       map[string]*calibrationSession
+	calibrationRecords map[string]calibrationRecord
+	calibrationFile    string
+	calibrationEnabled bool
+
+	// how often StreamTicks polls the counters
+	tickPollInterval time.Duration
+	// the timeout of the output watchdog armed by the board, 0 if it is not armed
+	outputWatchdog time.Duration
+	// digital inputs whose edges are counted in software, in the configured order
+	softwareInterrupts     map[string]*softwareInterrupt
+	softwareInterruptNames []string
 
 	controlChip             *gpioChip
 	cancelCtx               context.Context
@@ -53,76 +78,213 @@ func newBoard(
 ) (board.Board, error) {
 	logger.Info("Starting RevolutionPi Driver v0.0.9")
 
-	devPath := filepath.Join("/dev", "piControl0")
-	devPath = filepath.Clean(devPath)
-	fd, err := os.OpenFile(devPath, os.O_RDWR, fs.FileMode(os.O_RDWR))
+	newConf, err := resource.NativeConfig[*Config](conf)
 	if err != nil {
-		err = fmt.Errorf("open chip %v failed: %w", devPath, err)
 		return nil, err
 	}
+	gpioChip, err := newGpioChip(newConf.Backend, newConf.PiCtoryConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	gpioChip.simulateInputs = newConf.SimulateInputs
+	calibrationFile := calibrationFilePath(newConf.CalibrationFile)
+	calibrationRecords, err := loadCalibrationRecords(calibrationFile)
+	if err != nil {
+		return nil, multierr.Combine(err, gpioChip.Close())
+	}
+	pins := gpioChip.enumeratePins()
 	cancelCtx, cancelFunc := context.WithCancel(context.Background())
-	gpioChip := gpioChip{dev: devPath, logger: logger, fileHandle: fd}
 	b := revolutionPiBoard{
-		Named:         conf.ResourceName().AsNamed(),
-		logger:        logger,
-		cancelCtx:     cancelCtx,
-		cancelFunc:    cancelFunc,
-		AnalogReaders: []string{},
-		GPIONames:     []string{},
-		controlChip:   &gpioChip,
-		mu:            sync.RWMutex{},
+		Named:              conf.ResourceName().AsNamed(),
+		logger:             logger,
+		cancelCtx:          cancelCtx,
+		cancelFunc:         cancelFunc,
+		AnalogReaders:      pins.analogs,
+		GPIONames:          pins.gpios,
+		DigitalInterrupts:  pins.interrupts,
+		controlChip:        gpioChip,
+		gpioPins:           map[string]*gpioPin{},
+		analogPins:         map[string]*analogPin{},
+		interruptPins:      map[string]*counterPin{},
+		encoderPins:        map[string]*counterPin{},
+		calibrations:       map[string]*calibrationSession{},
+		calibrationRecords: calibrationRecords,
+		calibrationFile:    calibrationFile,
+		calibrationEnabled: newConf.EnableAIOCalibration,
+		tickPollInterval:   defaultTickPollInterval,
+		softwareInterrupts: map[string]*softwareInterrupt{},
+		mu:                 sync.RWMutex{},
 	}
 
-	err = b.controlChip.showDeviceList()
-	if err != nil {
-		return nil, err
+	if newConf.OutputWatchdogMs > 0 {
+		err = b.startOutputWatchdog(time.Duration(newConf.OutputWatchdogMs) * time.Millisecond)
+		if err != nil {
+			return nil, multierr.Combine(err, b.Close(ctx))
+		}
+	}
+	if newConf.TickPollIntervalMs > 0 {
+		b.tickPollInterval = time.Duration(newConf.TickPollIntervalMs) * time.Millisecond
+	}
+	if len(newConf.SoftwareInterrupts) > 0 {
+		if err := b.addSoftwareInterrupts(newConf.SoftwareInterrupts); err != nil {
+			return nil, multierr.Combine(err, b.Close(ctx))
+		}
+		interval := defaultSoftwareInterruptPollInterval
+		if newConf.SoftwareInterruptPollMs > 0 {
+			interval = time.Duration(newConf.SoftwareInterruptPollMs) * time.Millisecond
+		}
+		b.startSoftwareInterrupts(interval)
+	}
+	if newConf.ReadCycleMs > 0 {
+		if err := gpioChip.enableReadCache(time.Duration(newConf.ReadCycleMs) * time.Millisecond); err != nil {
+			return nil, multierr.Combine(err, b.Close(ctx))
+		}
+		b.startReadCache()
+	}
+	if len(newConf.ExportedOutputs) > 0 {
+		if err := gpioChip.enableExportedOutputs(newConf.ExportedOutputs); err != nil {
+			return nil, multierr.Combine(err, b.Close(ctx))
+		}
+		cycle := defaultExportedOutputsCycle
+		if newConf.ExportedOutputsCycleMs > 0 {
+			cycle = time.Duration(newConf.ExportedOutputsCycleMs) * time.Millisecond
+		}
+		b.startExportedOutputs(cycle)
 	}
+	b.subscribeEvents()
 
 	return &b, nil
 }
 
-// StreamTicks starts a stream of digital interrupt ticks. The rev pi does not support this feature.
-func (b *revolutionPiBoard) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt,
-	ch chan board.Tick, extra map[string]interface{},
-) error {
-	return grpc.UnimplementedError
-}
-
 func (b *revolutionPiBoard) AnalogByName(name string) (board.Analog, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stale, ok := b.analogPins[name]
+	if ok && stale.initialized {
+		return stale, nil
+	}
+	b.controlChip.configMu.Lock()
+	defer b.controlChip.configMu.Unlock()
 	pin, err := b.controlChip.GetAnalogPin(name)
 	if err != nil {
 		b.logger.Error(err)
 		return nil, err
 	}
 	b.logger.Debugf("Analog Pin: %#v", pin)
+	if ok {
+		// the analog was lost in a configuration reload, revive it for every component still holding it
+		*stale = *pin
+		return stale, nil
+	}
+	b.analogPins[name] = pin
 	return pin, nil
 }
 
 // DigitalInterruptByName returns a digital interrupt. The rev pi only supports the Value API.
 func (b *revolutionPiBoard) DigitalInterruptByName(name string) (board.DigitalInterrupt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if si, ok := b.softwareInterrupts[name]; ok {
+		return si, nil
+	}
+	stale, ok := b.interruptPins[name]
+	if ok && stale.enabled {
+		return &diWrapper{pin: stale}, nil
+	}
+	b.controlChip.configMu.Lock()
+	defer b.controlChip.configMu.Unlock()
 	interrupt, err := b.controlChip.GetDigitalInterrupt(name)
 	if err != nil {
 		b.logger.Error(err)
 		return nil, err
 	}
 	b.logger.Debugf("Interrupt Pin: %#v", interrupt)
+	if ok {
+		// the interrupt was lost in a configuration reload, revive it for every component still holding it
+		*stale = *interrupt
+		return &diWrapper{pin: stale}, nil
+	}
+	b.interruptPins[name] = interrupt
 	return &diWrapper{pin: interrupt}, nil
 }
 
+// encoderPin returns a counter configured for encoder mode, for an encoder component that uses the board.
+func (b *revolutionPiBoard) encoderPin(name string) (*counterPin, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stale, ok := b.encoderPins[name]
+	if ok && stale.enabled {
+		return stale, nil
+	}
+	b.controlChip.configMu.Lock()
+	defer b.controlChip.configMu.Unlock()
+	pin, err := b.controlChip.GetEncoder(name)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// the counter was lost in a configuration reload, revive it for every encoder still holding it
+		*stale = *pin
+		return stale, nil
+	}
+	b.encoderPins[name] = pin
+	return pin, nil
+}
+
+// digitalInputPin returns a digital input of the board for an encoder that decodes its inputs in software.
+func (b *revolutionPiBoard) digitalInputPin(name string) (*gpioPin, error) {
+	pin, err := b.GPIOPinByName(name)
+	if err != nil {
+		return nil, err
+	}
+	gpio, ok := pin.(*gpioPin)
+	if !ok || !gpio.isDigitalInput() {
+		return nil, fmt.Errorf("encoder pin %s must be a digital input", name)
+	}
+	return gpio, nil
+}
+
+// AnalogNames returns the analog inputs and enabled analog outputs found when the board started.
 func (b *revolutionPiBoard) AnalogNames() []string {
-	return nil
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]string{}, b.AnalogReaders...)
 }
 
+// DigitalInterruptNames returns the counters configured for counter/interrupt mode found when the board started.
 func (b *revolutionPiBoard) DigitalInterruptNames() []string {
-	return nil
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]string{}, b.DigitalInterrupts...)
 }
 
+// GPIOPinNames returns the digital inputs, digital outputs and enabled PWM outputs found when the board started.
 func (b *revolutionPiBoard) GPIOPinNames() []string {
-	return nil
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]string{}, b.GPIONames...)
 }
 
 func (b *revolutionPiBoard) GPIOPinByName(pinName string) (board.GPIOPin, error) {
-	return b.controlChip.GetGPIOPin(pinName)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stale, ok := b.gpioPins[pinName]
+	if ok && stale.initialized {
+		return stale, nil
+	}
+	b.controlChip.configMu.Lock()
+	defer b.controlChip.configMu.Unlock()
+	pin, err := b.controlChip.GetGPIOPin(pinName)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// the pin was lost in a configuration reload, revive it for every component still holding it
+		*stale = *pin
+		return stale, nil
+	}
+	b.gpioPins[pinName] = pin
+	return pin, nil
 }
 
 func (b *revolutionPiBoard) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration) error {
@@ -130,15 +292,37 @@ func (b *revolutionPiBoard) SetPowerMode(ctx context.Context, mode pb.PowerMode,
 }
 
 func (b *revolutionPiBoard) Close(ctx context.Context) error {
-	b.mu.Lock()
 	b.logger.Info("Closing RevPi board.")
-	defer b.mu.Unlock()
+	// stop the background workers and events first, a configuration reload holds the lock while it runs
+	if err := b.unsubscribeEvents(); err != nil {
+		b.logger.Debugf("failed to stop the event listener: %v", err)
+	}
 	b.cancelFunc()
-	err := b.controlChip.Close()
+	b.activeBackgroundWorkers.Wait()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// never leave the outputs frozen after the module is gone
+	if b.controlChip.ioStopped.Load() {
+		if _, err := b.controlChip.setIOStopped(false); err != nil {
+			b.logger.Errorf("failed to restart IO communication: %v", err)
+		}
+	}
+	if b.outputWatchdog > 0 {
+		// encoders may keep using the chip, and nothing refreshes the watchdog of its handle anymore
+		if err := b.controlChip.setOutputWatchdog(0); err != nil {
+			b.logger.Errorf("failed to disarm the output watchdog: %v", err)
+		}
+	}
+	if b.controlChip.cache != nil {
+		// encoders may keep using the chip, and the snapshot is no longer refreshed
+		b.controlChip.configMu.Lock()
+		b.controlChip.cache = nil
+		b.controlChip.configMu.Unlock()
+	}
+	err := b.controlChip.release()
 	if err != nil {
 		return err
 	}
-	b.activeBackgroundWorkers.Wait()
 	b.logger.Info("Board closed.")
 	return nil
 }
@@ -146,45 +330,124 @@ func (b *revolutionPiBoard) Close(ctx context.Context) error {
 func (b *revolutionPiBoard) DoCommand(ctx context.Context,
 	req map[string]interface{},
 ) (map[string]interface{}, error) {
+	return runCommands(ctx, b.commands(), req)
+}
+
+// boardCommand is a DoCommand supported by the board or one of its components. The handler receives the value
+// given for the key and adds its results to the response.
+type boardCommand struct {
+	key     string
+	handler func(ctx context.Context, value interface{}, resp map[string]interface{}) error
+}
+
+// runCommands runs the commands whose key is part of req and combines their results.
+func runCommands(ctx context.Context, commands []boardCommand, req map[string]interface{}) (map[string]interface{}, error) {
 	resp := make(map[string]interface{})
 
-	pinMessage, exists := req[readParameterKey]
-	if exists {
-		pinName, ok := pinMessage.(string)
-		if !ok {
-			return nil, fmt.Errorf("error performing %s: expected string got %v", readParameterKey, pinMessage)
+	handled := false
+	for _, command := range commands {
+		value, exists := req[command.key]
+		if !exists {
+			continue
 		}
-		pin := SPIVariable{strVarName: char32(pinName)}
-		err := b.controlChip.mapNameToAddress(&pin)
-		if err != nil {
+		handled = true
+		if err := command.handler(ctx, value, resp); err != nil {
 			return nil, err
 		}
-		b.controlChip.logger.Debugf("reading pin: %#v", pin)
-		switch pin.i16uLength {
-		case 1:
-			// the length of the variable is 1, so we want to read from a specific bit at the address
-			value, err := b.controlChip.getBitValue(int64(pin.i16uAddress), pin.i8uBit)
-			if err != nil {
-				return nil, err
-			}
-			resp[pinName] = value
-		default:
-			// the length of the variable is more than 1, so we want to read a set of bytes from the address
-			value := make([]byte, pin.i16uLength/8)
-			n, err := b.controlChip.fileHandle.ReadAt(value, int64(pin.i16uAddress))
-			if err != nil {
-				return nil, err
-			}
-			b.controlChip.logger.Debugf("Read %#d bytes", n)
-			resp[pinName], err = readFromBuffer(value, n)
-			if err != nil {
-				return nil, err
-			}
-		}
 	}
-	if !exists {
+	if !handled {
 		return nil, fmt.Errorf("no valid commands found, got %#v", req)
 	}
 
 	return resp, nil
 }
+
+// commands returns the DoCommands supported by the board, in the order they are run.
+func (b *revolutionPiBoard) commands() []boardCommand {
+	return []boardCommand{
+		{key: readParameterKey, handler: b.readParameter},
+		{key: resetCounterKey, handler: b.resetCounter},
+		{key: lastMessageKey, handler: b.lastDriverMessage},
+		{key: stopIOKey, handler: b.stopIO},
+		{key: startIOKey, handler: b.startIO},
+		{key: reloadConfigKey, handler: b.reloadConfigCommand},
+		{key: eventsKey, handler: b.events},
+		{key: writeManyKey, handler: b.writeMany},
+		{key: readManyKey, handler: b.readMany},
+		{key: listDevicesKey, handler: b.listDevices},
+		{key: calibrateStartKey, handler: b.calibrateStart},
+		{key: calibratePointKey, handler: b.calibratePoint},
+		{key: calibrateCommitKey, handler: b.calibrateCommit},
+		{key: calibrationRecordsKey, handler: b.listCalibrationRecords},
+	}
+}
+
+// readParameter reads any variable from the process image by name.
+func (b *revolutionPiBoard) readParameter(ctx context.Context, pinMessage interface{}, resp map[string]interface{}) error {
+	pinName, ok := pinMessage.(string)
+	if !ok {
+		return fmt.Errorf("error performing %s: expected string got %v", readParameterKey, pinMessage)
+	}
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+	pin := SPIVariable{strVarName: char32(pinName)}
+	err := b.controlChip.mapNameToAddress(&pin)
+	if err != nil {
+		return err
+	}
+	b.controlChip.logger.Debugf("reading pin: %#v", pin)
+	switch pin.i16uLength {
+	case 1:
+		// the length of the variable is 1, so we want to read from a specific bit at the address
+		value, err := b.controlChip.getBitValue(int64(pin.i16uAddress), pin.i8uBit)
+		if err != nil {
+			return err
+		}
+		resp[pinName] = value
+	default:
+		// the length of the variable is more than 1, so we want to read a set of bytes from the address
+		value := make([]byte, pin.i16uLength/8)
+		n, err := b.controlChip.readAt(value, int64(pin.i16uAddress))
+		if err != nil {
+			return err
+		}
+		b.controlChip.logger.Debugf("Read %#d bytes", n)
+		resp[pinName], err = readFromBuffer(value, n)
+		if err != nil {
+			return err
+		}
+	}
+	if b.controlChip.cache != nil {
+		resp[snapshotTimeKey] = b.controlChip.cache.snapshotTime().Format(time.RFC3339Nano)
+	}
+	return nil
+}
+
+// resetCounter sets a counter or encoder on a DIO module back to 0.
+func (b *revolutionPiBoard) resetCounter(ctx context.Context, pinMessage interface{}, resp map[string]interface{}) error {
+	pinName, ok := pinMessage.(string)
+	if !ok {
+		return fmt.Errorf("error performing %s: expected string got %v", resetCounterKey, pinMessage)
+	}
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+	counter, err := b.controlChip.GetCounter(pinName)
+	if err != nil {
+		return err
+	}
+	if err := counter.resetLocked(); err != nil {
+		return err
+	}
+	resp[resetCounterKey] = pinName
+	return nil
+}
+
+// lastDriverMessage returns the last error message reported by the piControl driver.
+func (b *revolutionPiBoard) lastDriverMessage(ctx context.Context, _ interface{}, resp map[string]interface{}) error {
+	message, err := b.controlChip.lastDriverMessage()
+	if err != nil {
+		return err
+	}
+	resp[lastMessageKey] = message
+	return nil
+}
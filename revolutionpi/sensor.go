@@ -0,0 +1,230 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/grpc"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+// the types a sensor variable can be read as
+const (
+	typeBool    = "bool"
+	typeU8      = "u8"
+	typeS8      = "s8"
+	typeU16     = "u16"
+	typeS16     = "s16"
+	typeU32     = "u32"
+	typeS32     = "s32"
+	typeFloat32 = "float32"
+)
+
+// variableTypeSizes is the size in bytes of every type except bool, which can be read from any variable.
+var variableTypeSizes = map[string]uint16{
+	typeU8: 1, typeS8: 1, typeU16: 2, typeS16: 2, typeU32: 4, typeS32: 4, typeFloat32: 4,
+}
+
+// SensorModel is the model triplet for the rev-pi process image sensor.
+var SensorModel = resource.NewModel("viam-labs", "kunbus", "revolutionpi-sensor")
+
+// SensorVariableConfig configures a variable returned by the sensor.
+type SensorVariableConfig struct {
+	Name string `json:"name"`
+	// Type is how the variable is decoded. Defaults to bool for bits and to an unsigned integer of the
+	// variable's size otherwise.
+	Type string `json:"type,omitempty"`
+	// Scale multiplies the value. Numbers are returned as float64 if Scale or Offset is set.
+	Scale float64 `json:"scale,omitempty"`
+	// Offset is added to the value after it is scaled.
+	Offset float64 `json:"offset,omitempty"`
+}
+
+// Validate validates the SensorVariableConfig.
+func (cfg *SensorVariableConfig) Validate(path string) error {
+	if cfg.Name == "" {
+		return utils.NewConfigValidationFieldRequiredError(path, "name")
+	}
+	if _, ok := variableTypeSizes[cfg.Type]; !ok && cfg.Type != "" && cfg.Type != typeBool {
+		return fmt.Errorf("variable %s has unknown type %q", cfg.Name, cfg.Type)
+	}
+	if cfg.Type == typeBool && (cfg.Scale != 0 || cfg.Offset != 0) {
+		return fmt.Errorf("variable %s is a bool and cannot be scaled", cfg.Name)
+	}
+	return nil
+}
+
+// scaled reports whether the value of the variable is scaled.
+func (cfg *SensorVariableConfig) scaled() bool {
+	return cfg.Scale != 0 || cfg.Offset != 0
+}
+
+// SensorConfig is the config for the rev-pi process image sensor.
+type SensorConfig struct {
+	// Board is the revolution pi board the variables are read from.
+	Board     string                 `json:"board"`
+	Variables []SensorVariableConfig `json:"variables"`
+}
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		SensorModel,
+		resource.Registration[sensor.Sensor, *SensorConfig]{Constructor: newSensor})
+}
+
+// Validate validates the SensorConfig.
+func (cfg *SensorConfig) Validate(path string) ([]string, error) {
+	if cfg.Board == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "board")
+	}
+	if len(cfg.Variables) == 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "variables")
+	}
+	names := map[string]bool{}
+	for i, variable := range cfg.Variables {
+		if err := variable.Validate(fmt.Sprintf("%s.variables.%d", path, i)); err != nil {
+			return nil, resource.NewConfigValidationError(path, err)
+		}
+		if names[variable.Name] {
+			return nil, resource.NewConfigValidationError(path, fmt.Errorf("variable %s is listed more than once", variable.Name))
+		}
+		names[variable.Name] = true
+	}
+	return []string{cfg.Board}, nil
+}
+
+// revolutionPiSensor returns variables of the process image as sensor readings.
+type revolutionPiSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+	// chip is the board's chip, shared with the board
+	chip      *gpioChip
+	variables []SensorVariableConfig
+	names     []string
+}
+
+func newSensor(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	newConf, err := resource.NativeConfig[*SensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	revPi, err := revolutionPiBoardFromDependencies(deps, newConf.Board)
+	if err != nil {
+		return nil, err
+	}
+	s := &revolutionPiSensor{
+		Named:     conf.ResourceName().AsNamed(),
+		logger:    logger,
+		chip:      revPi.controlChip.acquire(),
+		variables: newConf.Variables,
+	}
+	for _, variable := range newConf.Variables {
+		s.names = append(s.names, variable.Name)
+	}
+	// fail early if a variable does not exist or does not match its type
+	if _, err := s.Readings(ctx, nil); err != nil {
+		return nil, multierr.Combine(err, s.chip.release())
+	}
+	return s, nil
+}
+
+// Readings returns every configured variable, read from the same cycle of the process image.
+func (s *revolutionPiSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	chip := s.chip
+	chip.configMu.RLock()
+	defer chip.configMu.RUnlock()
+
+	pins, start, span, err := chip.readVariables(s.names)
+	if err != nil {
+		return nil, err
+	}
+	readings := map[string]interface{}{}
+	for i, pin := range pins {
+		value, err := variableReading(s.variables[i], pin, span[int64(pin.i16uAddress)-start:])
+		if err != nil {
+			return nil, err
+		}
+		readings[s.variables[i].Name] = value
+	}
+	return readings, nil
+}
+
+// variableReading decodes a variable from the process image bytes starting at its address.
+func variableReading(cfg SensorVariableConfig, pin SPIVariable, data []byte) (interface{}, error) {
+	if pin.i16uLength == 1 {
+		if cfg.Type != "" && cfg.Type != typeBool {
+			return nil, fmt.Errorf("variable %s is a bit and can only be read as a bool", cfg.Name)
+		}
+		return (data[0]>>pin.i8uBit)&1 == 1, nil
+	}
+	size := pin.i16uLength / 8
+	typ := cfg.Type
+	if typ == "" {
+		typ = map[uint16]string{1: typeU8, 2: typeU16, 4: typeU32}[size]
+	}
+	if typ == typeBool {
+		for _, b := range data[:size] {
+			if b != 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if variableTypeSizes[typ] != size {
+		return nil, fmt.Errorf("variable %s has %d bytes and cannot be read as %s", cfg.Name, size, typ)
+	}
+
+	var number float64
+	switch typ {
+	case typeU8:
+		number = float64(data[0])
+	case typeS8:
+		number = float64(int8(data[0]))
+	case typeU16:
+		number = float64(binary.LittleEndian.Uint16(data))
+	case typeS16:
+		number = float64(int16(binary.LittleEndian.Uint16(data)))
+	case typeU32:
+		number = float64(binary.LittleEndian.Uint32(data))
+	case typeS32:
+		number = float64(int32(binary.LittleEndian.Uint32(data)))
+	case typeFloat32:
+		number = float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	}
+	if !cfg.scaled() {
+		if typ == typeFloat32 {
+			return number, nil
+		}
+		// int64 holds every integer type, and can be converted to a protobuf value unlike the smaller types
+		return int64(number), nil
+	}
+	scale := cfg.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return number*scale + cfg.Offset, nil
+}
+
+func (s *revolutionPiSensor) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return nil, grpc.UnimplementedError
+}
+
+func (s *revolutionPiSensor) Close(ctx context.Context) error {
+	return s.chip.release()
+}
@@ -0,0 +1,478 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	processImageSize = 4096 // size of the piControl process image in bytes
+	// the return value of a failed ioctl, matching what the syscall package returns.
+	simulatedFailure = ^uintptr(0)
+)
+
+// simulatedVariable describes a piCtory variable relative to the first byte of its module.
+type simulatedVariable struct {
+	name    string
+	offset  uint16 // offset from the first input byte of the module
+	bit     uint8  // 0-7 bit position, only used when length is 1
+	length  uint16 // length of the variable in bits. Possible values are 1, 8, 16 and 32
+	initial int64  // value written to the process image when the module is added
+}
+
+// simulatedImage is an in-memory processImage that emulates the piControl driver.
+// It holds a process image, a device list and a variable table, so the board and encoder
+// logic can run without a Revolution Pi. Inputs are never refreshed by IO communication,
+// so any value written to the process image stays there until it is written again.
+type simulatedImage struct {
+	mu        sync.Mutex
+	image     [processImageSize]byte
+	devices   []SDeviceInfo
+	variables map[string]SPIVariable
+	// the last error message, returned by kbGetLastMessage
+	lastMessage string
+	// whether IO communication was stopped with kbStopIO. The simulator never refreshes inputs,
+	// so this only reports the state back to the caller.
+	ioStopped bool
+
+	watchdogTimeout  time.Duration
+	watchdogDeadline time.Time
+
+	// the config.rsc the devices were loaded from, reloaded by kbReset
+	configPath string
+	// events reported by kbWaitForEvent, closed is closed once the image is closed
+	events    chan int32
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// emptySimulatedImage returns a simulated process image without any devices.
+func emptySimulatedImage() *simulatedImage {
+	return &simulatedImage{
+		variables: map[string]SPIVariable{},
+		events:    make(chan int32, 1),
+		closed:    make(chan struct{}),
+	}
+}
+
+// newSimulatedImage returns a simulated RevPi Core with one DIO and one AIO module using
+// the default piCtory settings, except that both analog outputs are enabled for 0-10V.
+func newSimulatedImage() *simulatedImage {
+	sim := emptySimulatedImage()
+	offset := uint16(0)
+	sim.addDevice(0, 95, offset, coreInputLength, coreOutputLength, 0, simulatedCoreVariables())
+	offset += coreInputLength + coreOutputLength
+	sim.addDevice(32, 96, offset, dioInputLength, dioOutputLength, dioConfigLength, simulatedDIOVariables())
+	offset += dioInputLength + dioOutputLength + dioConfigLength
+	sim.addDevice(33, 103, offset, aioInputLength, aioOutputLength, aioConfigLength, simulatedAIOVariables())
+	return sim
+}
+
+// newSimulatedImageFromConfig returns a simulated process image with the devices, variables
+// and default values of a PiCtory config.rsc file.
+func newSimulatedImageFromConfig(path string) (*simulatedImage, error) {
+	sim := emptySimulatedImage()
+	sim.configPath = path
+	if err := sim.loadConfig(path); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// loadConfig adds the devices of a PiCtory config.rsc file to the simulated process image.
+func (sim *simulatedImage) loadConfig(path string) error {
+	cfg, err := readPiCtoryConfig(path)
+	if err != nil {
+		return err
+	}
+	for i := range cfg.Devices {
+		dev := &cfg.Devices[i]
+		var sections [3][]piCtoryVariable
+		for j, section := range []map[string][]json.RawMessage{dev.Inputs, dev.Outputs, dev.Memory} {
+			sections[j], err = dev.variables(section)
+			if err != nil {
+				return err
+			}
+		}
+		inputLength := variablesEnd(sections[0])
+		outputLength := subtractLength(variablesEnd(sections[1]), inputLength)
+		configLength := subtractLength(variablesEnd(sections[2]), inputLength+outputLength)
+		if int(dev.Offset)+int(inputLength+outputLength+configLength) > processImageSize {
+			return fmt.Errorf("device %s does not fit in the process image", dev.Name)
+		}
+
+		var variables []simulatedVariable
+		for _, section := range sections {
+			for _, v := range section {
+				variables = append(variables, simulatedVariable{
+					name: v.name, offset: v.offset, bit: v.bit, length: v.length, initial: v.defaultValue,
+				})
+			}
+		}
+		sim.addDevice(uint8(dev.Position), uint16(dev.ProductType), uint16(dev.Offset),
+			inputLength, outputLength, configLength, variables)
+	}
+	return nil
+}
+
+// reset emulates kbReset: the configuration is reloaded, IO communication restarts and a reset event is reported.
+func (sim *simulatedImage) reset() (uintptr, syscall.Errno) {
+	if sim.configPath != "" {
+		fresh := emptySimulatedImage()
+		if err := fresh.loadConfig(sim.configPath); err != nil {
+			return sim.fail(unix.EINVAL, "failed to reload %s: %v", sim.configPath, err)
+		}
+		sim.image = fresh.image
+		sim.devices = fresh.devices
+		sim.variables = fresh.variables
+	}
+	sim.ioStopped = false
+	select {
+	case sim.events <- int32(piControlEventReset):
+	default:
+		// a reset event is already pending
+	}
+	return 0, 0
+}
+
+// subtractLength returns the length of a section that ends at sectionEnd and starts at start.
+func subtractLength(sectionEnd, start uint16) uint16 {
+	if sectionEnd < start {
+		return 0
+	}
+	return sectionEnd - start
+}
+
+// addDevice adds a module at the given offset of the process image and registers its variables.
+func (sim *simulatedImage) addDevice(address uint8, moduleType, offset, inputLength, outputLength, configLength uint16,
+	variables []simulatedVariable,
+) {
+	sim.devices = append(sim.devices, SDeviceInfo{
+		i8uAddress:       address,
+		i32uSerialnumber: 1000 + uint32(address),
+		i16uModuleType:   moduleType,
+		i16uHWRevision:   1,
+		i16uSWMajor:      1,
+		i16uInputLength:  inputLength,
+		i16uOutputLength: outputLength,
+		i16uConfigLength: configLength,
+		i16uBaseOffset:   offset,
+		i16uInputOffset:  offset,
+		i16uOutputOffset: offset + inputLength,
+		i16uConfigOffset: offset + inputLength + outputLength,
+		i8uActive:        1,
+	})
+	for _, v := range variables {
+		spi := SPIVariable{strVarName: char32(v.name), i16uAddress: offset + v.offset, i8uBit: v.bit, i16uLength: v.length}
+		sim.variables[v.name] = spi
+		sim.setVariable(spi, v.initial)
+	}
+}
+
+// setVariable writes value into the process image at the location of the variable.
+func (sim *simulatedImage) setVariable(v SPIVariable, value int64) {
+	switch v.i16uLength {
+	case 1:
+		if value != 0 {
+			sim.image[v.i16uAddress] |= 1 << v.i8uBit
+		} else {
+			sim.image[v.i16uAddress] &^= 1 << v.i8uBit
+		}
+	case 8:
+		sim.image[v.i16uAddress] = byte(value)
+	case 16:
+		binary.LittleEndian.PutUint16(sim.image[v.i16uAddress:], uint16(value))
+	case 32:
+		binary.LittleEndian.PutUint32(sim.image[v.i16uAddress:], uint32(value))
+	}
+}
+
+// checkWatchdog sets every output to 0 if the output watchdog has expired.
+// The simulator checks the watchdog whenever the process image is accessed.
+func (sim *simulatedImage) checkWatchdog() {
+	if sim.watchdogTimeout == 0 || time.Now().Before(sim.watchdogDeadline) {
+		return
+	}
+	for _, dev := range sim.devices {
+		outputs := sim.image[dev.i16uOutputOffset : dev.i16uOutputOffset+dev.i16uOutputLength]
+		for i := range outputs {
+			outputs[i] = 0
+		}
+	}
+}
+
+func (sim *simulatedImage) ReadAt(b []byte, off int64) (int, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.checkWatchdog()
+	if off < 0 || off >= processImageSize {
+		sim.lastMessage = fmt.Sprintf("offset %d is outside of the process image", off)
+		return 0, io.EOF
+	}
+	n := copy(b, sim.image[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (sim *simulatedImage) WriteAt(b []byte, off int64) (int, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.checkWatchdog()
+	// only writes that reach the process image restart the watchdog, an empty write is not relied on
+	if len(b) > 0 {
+		sim.watchdogDeadline = time.Now().Add(sim.watchdogTimeout)
+	}
+	if off < 0 || off >= processImageSize {
+		sim.lastMessage = fmt.Sprintf("offset %d is outside of the process image", off)
+		return 0, errors.New(sim.lastMessage)
+	}
+	n := copy(sim.image[off:], b)
+	if n < len(b) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+//nolint:gosec
+func (sim *simulatedImage) ioCtl(command uintptr, message unsafe.Pointer) (uintptr, syscall.Errno) {
+	// waiting for an event blocks, so it must not hold the lock
+	if int(command) == kbWaitForEvent {
+		select {
+		case event := <-sim.events:
+			*(*int32)(message) = event
+			return 0, 0
+		case <-sim.closed:
+			return simulatedFailure, unix.EINTR
+		}
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.checkWatchdog()
+	switch int(command) {
+	case kbFindVariable:
+		pin := (*SPIVariable)(message)
+		v, ok := sim.variables[str32(pin.strVarName)]
+		if !ok {
+			return sim.fail(unix.ENOENT, "variable %s not found", str32(pin.strVarName))
+		}
+		*pin = v
+	case kbGetDeviceInfoList:
+		deviceInfoList := (*[255]SDeviceInfo)(message)
+		return uintptr(copy(deviceInfoList[:], sim.devices)), 0
+	case kbGetDeviceInfo:
+		dev := (*SDeviceInfo)(message)
+		for _, d := range sim.devices {
+			if d.i8uAddress == dev.i8uAddress {
+				*dev = d
+				return 0, 0
+			}
+		}
+		return sim.fail(unix.ENXIO, "no device at address %d", dev.i8uAddress)
+	case kbGetValue:
+		value := (*SPIValue)(message)
+		if value.i16uAddress >= processImageSize {
+			return sim.fail(unix.EFAULT, "address %d is outside of the process image", value.i16uAddress)
+		}
+		value.i8uValue = sim.image[value.i16uAddress]
+		if value.i8uBit < 8 {
+			value.i8uValue = (value.i8uValue >> value.i8uBit) & 1
+		}
+	case kbSetValue:
+		value := (*SPIValue)(message)
+		if value.i16uAddress >= processImageSize {
+			return sim.fail(unix.EFAULT, "address %d is outside of the process image", value.i16uAddress)
+		}
+		if value.i8uBit >= 8 {
+			sim.image[value.i16uAddress] = value.i8uValue
+		} else {
+			sim.setVariable(SPIVariable{i16uAddress: value.i16uAddress, i8uBit: value.i8uBit, i16uLength: 1}, int64(value.i8uValue))
+		}
+	case kbDIOResetCounter:
+		command := (*SDIOResetCounter)(message)
+		for _, dev := range sim.devices {
+			if dev.i8uAddress != command.i8uAddress {
+				continue
+			}
+			if !dev.isDIO() {
+				return sim.fail(unix.EINVAL, "device at address %d is not a DIO module", command.i8uAddress)
+			}
+			for i := uint16(0); i < 16; i++ {
+				if command.i16uBitfield&(1<<i) != 0 {
+					binary.LittleEndian.PutUint32(sim.image[dev.i16uInputOffset+inputWordToCounterOffset+4*i:], 0)
+				}
+			}
+			return 0, 0
+		}
+		return sim.fail(unix.ENXIO, "no device at address %d", command.i8uAddress)
+	case kbGetLastMessage:
+		copy((*[driverMessageLength]byte)(message)[:driverMessageLength-1], sim.lastMessage)
+	case kbStopIO:
+		switch *(*int32)(message) {
+		case 0:
+			sim.ioStopped = false
+		case 1:
+			sim.ioStopped = true
+		case 2:
+			sim.ioStopped = !sim.ioStopped
+		default:
+			return sim.fail(unix.EINVAL, "invalid stop IO request %d", *(*int32)(message))
+		}
+		if sim.ioStopped {
+			return 1, 0
+		}
+	case kbSetOutputWatchdog:
+		sim.watchdogTimeout = time.Duration(*(*uint32)(message)) * time.Millisecond
+		sim.watchdogDeadline = time.Now().Add(sim.watchdogTimeout)
+	case kbReset:
+		return sim.reset()
+	case kbSetExportedOutputs:
+		// the simulator has no export flags, so every output is treated as exported
+		image := (*[processImageSize]byte)(message)
+		for _, dev := range sim.devices {
+			end := dev.i16uOutputOffset + dev.i16uOutputLength
+			copy(sim.image[dev.i16uOutputOffset:end], image[dev.i16uOutputOffset:end])
+		}
+	case kbAIOCalibrate:
+		request := (*SAIOCalibrate)(message)
+		for _, dev := range sim.devices {
+			if dev.i8uAddress != request.i8uAddress {
+				continue
+			}
+			if !dev.isAIO() {
+				return sim.fail(unix.EINVAL, "device at address %d is not an AIO module", request.i8uAddress)
+			}
+			if request.i8uChannels == 0 || request.i8uChannels&^0x3f != 0 {
+				return sim.fail(unix.EINVAL, "invalid calibration channels %#x", request.i8uChannels)
+			}
+			if request.i8uMode > uint8(aioCalibrateCommit) {
+				return sim.fail(unix.EINVAL, "invalid calibration mode %d", request.i8uMode)
+			}
+			// the simulated module has no analog front end, so there is nothing to trim
+			return 0, 0
+		}
+		return sim.fail(unix.ENXIO, "no device at address %d", request.i8uAddress)
+	default:
+		return sim.fail(unix.ENOTTY, "command %#x is not supported by the simulator", command)
+	}
+	return 0, 0
+}
+
+// fail records the message for kbGetLastMessage and returns the result of a failed ioctl.
+func (sim *simulatedImage) fail(errno syscall.Errno, format string, args ...interface{}) (uintptr, syscall.Errno) {
+	sim.lastMessage = fmt.Sprintf(format, args...)
+	return simulatedFailure, errno
+}
+
+func (sim *simulatedImage) Close() error {
+	sim.closeOnce.Do(func() { close(sim.closed) })
+	return nil
+}
+
+// process image layout of the modules supported by the simulator, relative to the first input byte.
+const (
+	coreInputLength  = 6
+	coreOutputLength = 5
+	dioInputLength   = 70
+	dioOutputLength  = 18
+	dioConfigLength  = 25
+	aioInputLength   = 20
+	aioOutputLength  = 4
+	aioConfigLength  = 65
+)
+
+func simulatedCoreVariables() []simulatedVariable {
+	return []simulatedVariable{
+		{name: "RevPiStatus", offset: 0, length: 8, initial: 1},
+		{name: "RevPiIOCycle", offset: 1, length: 8, initial: 5},
+		{name: "RevPiIOErrorCount", offset: 2, length: 16},
+		{name: "Core_Temperature", offset: 4, length: 8, initial: 45},
+		{name: "Core_Frequency", offset: 5, length: 8, initial: 120},
+		{name: "RevPiLED", offset: 6, length: 8},
+		{name: "RS485ErrorLimit1", offset: 7, length: 16, initial: 10},
+		{name: "RS485ErrorLimit2", offset: 9, length: 16, initial: 1000},
+	}
+}
+
+func simulatedDIOVariables() []simulatedVariable {
+	vars := []simulatedVariable{
+		{name: "Status", offset: 2, length: 16},
+		{name: "OutputStatus", offset: 4, length: 16},
+		{name: "InputDebounce", offset: 104, length: 16},
+		{name: "OutputPushPull", offset: 106, length: 16},
+		{name: "OutputOpenLoadDetect", offset: 108, length: 16},
+		{name: "OutputPWMActive", offset: outputPWMActiveOffset, length: 16},
+		{name: "OutputPWMFrequency", offset: outputPWMFrequencyOffset, length: 8, initial: 1},
+	}
+	for i := uint16(0); i < 14; i++ {
+		vars = append(vars,
+			simulatedVariable{name: fmt.Sprintf("I_%d", i+1), offset: i / 8, bit: uint8(i % 8), length: 1},
+			simulatedVariable{name: fmt.Sprintf("O_%d", i+1), offset: dioInputLength + i/8, bit: uint8(i % 8), length: 1},
+		)
+	}
+	for i := uint16(0); i < 16; i++ {
+		vars = append(vars,
+			simulatedVariable{name: fmt.Sprintf("Counter_%d", i+1), offset: inputWordToCounterOffset + 4*i, length: 32},
+			simulatedVariable{name: fmt.Sprintf("PWM_%d", i+1), offset: dioInputLength + outputWordToPWMOffset + i, length: 8},
+			simulatedVariable{name: fmt.Sprintf("InputMode_%d", i+1), offset: inputModeOffset + i, length: 8},
+		)
+	}
+	return vars
+}
+
+func simulatedAIOVariables() []simulatedVariable {
+	vars := []simulatedVariable{
+		{name: "RTDValue_1", offset: 12, length: 16},
+		{name: "RTDValue_2", offset: 14, length: 16},
+		{name: "RTDStatus_1", offset: 16, length: 8},
+		{name: "RTDStatus_2", offset: 17, length: 8},
+		{name: "ADC_DataRate", offset: 52, length: 8},
+	}
+	for i := uint16(0); i < 4; i++ {
+		n := i + 1
+		rangeOffset := analogInputMemAddress + 7*i
+		vars = append(vars,
+			simulatedVariable{name: fmt.Sprintf("InputValue_%d", n), offset: 2 * i, length: 16},
+			simulatedVariable{name: fmt.Sprintf("InputStatus_%d", n), offset: 8 + i, length: 8},
+			simulatedVariable{name: fmt.Sprintf("InputRange_%d", n), offset: rangeOffset, length: 8, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("InputMultiplier_%d", n), offset: rangeOffset + 1, length: 16, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("InputDivisor_%d", n), offset: rangeOffset + 3, length: 16, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("InputOffset_%d", n), offset: rangeOffset + 5, length: 16},
+		)
+	}
+	for i := uint16(0); i < 2; i++ {
+		n := i + 1
+		rtdOffset := 53 + 8*i
+		outputOffset := 69 + 10*i
+		vars = append(vars,
+			simulatedVariable{name: fmt.Sprintf("RTDType_%d", n), offset: rtdOffset, length: 8},
+			simulatedVariable{name: fmt.Sprintf("RTDWiring_%d", n), offset: rtdOffset + 1, length: 8},
+			simulatedVariable{name: fmt.Sprintf("RTDMultiplier_%d", n), offset: rtdOffset + 2, length: 16, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("RTDDivisor_%d", n), offset: rtdOffset + 4, length: 16, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("RTDOffset_%d", n), offset: rtdOffset + 6, length: 16},
+			simulatedVariable{name: fmt.Sprintf("OutputStatus_%d", n), offset: 18 + i, length: 8},
+			simulatedVariable{name: fmt.Sprintf("OutputValue_%d", n), offset: aioInputLength + 2*i, length: 16},
+			simulatedVariable{name: fmt.Sprintf("OutputRange_%d", n), offset: outputOffset, length: 8, initial: 2},
+			simulatedVariable{name: fmt.Sprintf("OutputSlewRateEnabled_%d", n), offset: outputOffset + 1, length: 8},
+			simulatedVariable{name: fmt.Sprintf("OutputSlewRateStepSize_%d", n), offset: outputOffset + 2, length: 8},
+			simulatedVariable{name: fmt.Sprintf("OutputSlewRateUpdateFreq_%d", n), offset: outputOffset + 3, length: 8},
+			simulatedVariable{name: fmt.Sprintf("OutputMultiplier_%d", n), offset: outputOffset + 4, length: 16, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("OutputDivisor_%d", n), offset: outputOffset + 6, length: 16, initial: 1},
+			simulatedVariable{name: fmt.Sprintf("OutputOffset_%d", n), offset: outputOffset + 8, length: 16},
+		)
+	}
+	return vars
+}
@@ -0,0 +1,297 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+// newSimulatedBoard returns a board on the default simulated process image, closed when the test ends.
+func newSimulatedBoard(t testing.TB, conf *Config) *revolutionPiBoard {
+	t.Helper()
+	conf.Backend = backendSimulated
+	logger := logging.NewTestLogger(t)
+	if _, ok := t.(*testing.B); ok {
+		// debug logs would dominate the benchmarks
+		logger = logging.NewBlankLogger("board")
+	}
+	b, err := newBoard(context.Background(), nil,
+		resource.Config{Name: "board", API: board.API, Model: Model, ConvertedAttributes: conf}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := b.Close(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return b.(*revolutionPiBoard)
+}
+
+// writeVariable writes the raw bytes of a variable to the process image, bypassing the board.
+func writeVariable(t testing.TB, g *gpioChip, name string, value []byte) {
+	t.Helper()
+	pin := SPIVariable{strVarName: char32(name)}
+	if err := g.mapNameToAddress(&pin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.image.WriteAt(value, int64(pin.i16uAddress)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulatedFindVariable(t *testing.T) {
+	b := newSimulatedBoard(t, &Config{})
+
+	pin := SPIVariable{strVarName: char32("O_2")}
+	if err := b.controlChip.mapNameToAddress(&pin); err != nil {
+		t.Fatal(err)
+	}
+	dio := b.controlChip.dioDevices[0]
+	if pin.i16uAddress != dio.i16uOutputOffset || pin.i8uBit != 1 || pin.i16uLength != 1 {
+		t.Errorf("O_2 is at %d bit %d with %d bits, expected %d bit 1 with 1 bit",
+			pin.i16uAddress, pin.i8uBit, pin.i16uLength, dio.i16uOutputOffset)
+	}
+
+	missing := SPIVariable{strVarName: char32("NoSuchVariable")}
+	if err := b.controlChip.mapNameToAddress(&missing); err == nil {
+		t.Fatal("expected an error for a missing variable")
+	}
+	resp, err := b.DoCommand(context.Background(), map[string]interface{}{lastMessageKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message, _ := resp[lastMessageKey].(string); !strings.Contains(message, "NoSuchVariable") {
+		t.Errorf("last driver message %q does not name the missing variable", message)
+	}
+}
+
+func TestSimulatedDeviceList(t *testing.T) {
+	b := newSimulatedBoard(t, &Config{})
+
+	devices, err := b.controlChip.deviceInfoList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("expected 3 devices, got %d", len(devices))
+	}
+	if len(b.controlChip.dioDevices) != 1 || len(b.controlChip.aioDevices) != 1 {
+		t.Errorf("expected one DIO and one AIO module, got %d and %d",
+			len(b.controlChip.dioDevices), len(b.controlChip.aioDevices))
+	}
+	dev, err := b.controlChip.deviceInfo(devices[1].i8uAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev != devices[1] {
+		t.Errorf("kbGetDeviceInfo returned %#v, expected %#v", dev, devices[1])
+	}
+	if _, err := b.controlChip.deviceInfo(200); err == nil {
+		t.Error("expected an error for a missing device")
+	}
+}
+
+func TestSimulatedGPIO(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+
+	out, err := b.GPIOPinByName("O_3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Set(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	high, err := out.Get(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !high {
+		t.Error("O_3 is low after setting it high")
+	}
+	value := SPIValue{i16uAddress: b.controlChip.dioDevices[0].i16uOutputOffset, i8uBit: 2}
+	//nolint:gosec
+	if err := b.controlChip.ioCtl(uintptr(kbGetValue), unsafe.Pointer(&value)); err != nil {
+		t.Fatal(err)
+	}
+	if value.i8uValue != 1 {
+		t.Errorf("kbGetValue returned %d for O_3, expected 1", value.i8uValue)
+	}
+
+	in, err := b.GPIOPinByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set(ctx, true, nil); err == nil {
+		t.Error("expected an error writing an input without simulate_inputs")
+	}
+}
+
+func TestSimulatedInputSimulation(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{SimulateInputs: true})
+
+	in, err := b.GPIOPinByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set(ctx, true, nil); err == nil {
+		t.Error("expected an error writing an input while IO communication is running")
+	}
+	resp, err := b.DoCommand(ctx, map[string]interface{}{stopIOKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp["ioStopped"] != true {
+		t.Fatalf("stopIO returned %v", resp)
+	}
+	if err := in.Set(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if high, err := in.Get(ctx, nil); err != nil || !high {
+		t.Errorf("I_1 read %v, %v after simulating it high", high, err)
+	}
+	resp, err = b.DoCommand(ctx, map[string]interface{}{startIOKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp["ioStopped"] != false {
+		t.Errorf("startIO returned %v", resp)
+	}
+}
+
+func TestSimulatedResetCounter(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+	writeVariable(t, b.controlChip, "InputMode_1", []byte{inputModeCounterFalling})
+	writeVariable(t, b.controlChip, "InputMode_2", []byte{inputModeCounterFalling})
+	counter := make([]byte, 4)
+	binary.LittleEndian.PutUint32(counter, 1234)
+	writeVariable(t, b.controlChip, "Counter_1", counter)
+	writeVariable(t, b.controlChip, "Counter_2", counter)
+
+	interrupt, err := b.DigitalInterruptByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := interrupt.Value(ctx, nil); err != nil || value != 1234 {
+		t.Fatalf("counter read %d, %v, expected 1234", value, err)
+	}
+	if _, err := b.DoCommand(ctx, map[string]interface{}{resetCounterKey: "I_1"}); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := interrupt.Value(ctx, nil); err != nil || value != 0 {
+		t.Errorf("counter read %d, %v after the reset, expected 0", value, err)
+	}
+	// kbDIOResetCounter only resets the counters in its bitfield
+	other, err := b.DigitalInterruptByName("I_2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := other.Value(ctx, nil); err != nil || value != 1234 {
+		t.Errorf("the counter of I_2 read %d, %v after resetting I_1, expected 1234", value, err)
+	}
+
+	if _, err := b.DoCommand(ctx, map[string]interface{}{resetCounterKey: "I_3"}); err == nil {
+		t.Error("expected an error resetting an input that is not a counter")
+	}
+}
+
+func TestSimulatedOutputWatchdog(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+	out, err := b.GPIOPinByName("O_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Set(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.controlChip.setOutputWatchdog(20 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if high, err := out.Get(ctx, nil); err != nil || high {
+		t.Errorf("O_1 read %v, %v after the watchdog expired, expected it to be reset", high, err)
+	}
+}
+
+func TestSimulatedOutputWatchdogRefreshed(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{OutputWatchdogMs: 20})
+	out, err := b.GPIOPinByName("O_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Set(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if high, err := out.Get(ctx, nil); err != nil || !high {
+		t.Errorf("O_1 read %v, %v while the board refreshes the watchdog, expected it to stay high", high, err)
+	}
+}
+
+func TestSimulatedUnsupportedCommand(t *testing.T) {
+	b := newSimulatedBoard(t, &Config{})
+	if err := b.controlChip.ioCtl(uintptr(ioctlAddress(99)), nil); err == nil {
+		t.Error("expected an error for an unsupported command")
+	}
+}
+
+func TestSimulatedOutputWatchdogDisarmedOnClose(t *testing.T) {
+	ctx := context.Background()
+	// the board is closed by the test, so it is not created with newSimulatedBoard
+	res, err := newBoard(ctx, nil, resource.Config{
+		Name: "board", API: board.API, Model: Model,
+		ConvertedAttributes: &Config{Backend: backendSimulated, OutputWatchdogMs: 20},
+	}, logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := res.(*revolutionPiBoard)
+	out, err := b.GPIOPinByName("O_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Set(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	// an encoder keeps the chip open after the board closes
+	chip := b.controlChip.acquire()
+	if err := b.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	output := []byte{0}
+	if _, err := chip.image.ReadAt(output, int64(chip.dioDevices[0].i16uOutputOffset)); err != nil {
+		t.Fatal(err)
+	}
+	if output[0]&1 == 0 {
+		t.Error("O_1 was reset by the watchdog after the board closed")
+	}
+	if err := chip.release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulatedIOCycle(t *testing.T) {
+	b := newSimulatedBoard(t, &Config{})
+	cycle, err := b.controlChip.ioCycle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycle != 5*time.Millisecond {
+		t.Errorf("the IO cycle is %v, expected the simulated 5ms", cycle)
+	}
+}
@@ -0,0 +1,213 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/utils"
+)
+
+// defaultSoftwareInterruptPollInterval is how often software interrupts sample their inputs when no interval is configured.
+const defaultSoftwareInterruptPollInterval = time.Millisecond
+
+// softwareTickBuffer is the number of ticks of software interrupts buffered for a StreamTicks call. Ticks are
+// dropped rather than holding up the sampling of the inputs when a stream falls this far behind.
+const softwareTickBuffer = 1024
+
+const (
+	edgeRising  = "rising"
+	edgeFalling = "falling"
+	edgeBoth    = "both"
+)
+
+// SoftwareInterruptConfig configures a digital input that is counted in software instead of by the DIO module.
+type SoftwareInterruptConfig struct {
+	Name string `json:"name"`
+	// Edge selects the edges that are counted, "rising" (the default), "falling" or "both".
+	Edge string `json:"edge,omitempty"`
+	// DebounceMs is how long the input has to keep its new state before the change is counted.
+	DebounceMs int `json:"debounce_ms,omitempty"`
+}
+
+// Validate validates the SoftwareInterruptConfig.
+func (cfg *SoftwareInterruptConfig) Validate(path string) error {
+	if cfg.Name == "" {
+		return utils.NewConfigValidationFieldRequiredError(path, "name")
+	}
+	switch cfg.Edge {
+	case "", edgeRising, edgeFalling, edgeBoth:
+	default:
+		return fmt.Errorf("software interrupt %s has unknown edge %q, expected %q, %q or %q",
+			cfg.Name, cfg.Edge, edgeRising, edgeFalling, edgeBoth)
+	}
+	if cfg.DebounceMs < 0 {
+		return fmt.Errorf("software interrupt %s cannot have a negative debounce_ms", cfg.Name)
+	}
+	return nil
+}
+
+// tickSubscriber is a StreamTicks call listening to a software interrupt. The ticks are buffered in queue,
+// which the stream forwards to its channel.
+type tickSubscriber struct {
+	ctx   context.Context
+	queue chan board.Tick
+}
+
+// softwareInterrupt counts the edges of a plain digital input by sampling it from the board's poller.
+type softwareInterrupt struct {
+	name     string
+	pin      *gpioPin
+	rising   bool
+	falling  bool
+	debounce time.Duration
+	count    atomic.Int64
+
+	// debounce state, only used by the poller
+	stable       bool
+	candidate    bool
+	changedSince time.Time
+
+	subscribersMu sync.Mutex
+	subscribers   []tickSubscriber
+}
+
+func newSoftwareInterrupt(cfg SoftwareInterruptConfig, pin *gpioPin) (*softwareInterrupt, error) {
+	if !pin.isDigitalInput() {
+		return nil, fmt.Errorf("software interrupt %s must be a digital input", cfg.Name)
+	}
+	si := &softwareInterrupt{
+		name:     cfg.Name,
+		pin:      pin,
+		rising:   cfg.Edge == "" || cfg.Edge == edgeRising || cfg.Edge == edgeBoth,
+		falling:  cfg.Edge == edgeFalling || cfg.Edge == edgeBoth,
+		debounce: time.Duration(cfg.DebounceMs) * time.Millisecond,
+	}
+	state, err := pin.Get(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	si.stable = state
+	si.candidate = state
+	return si, nil
+}
+
+// Name returns the name of the input.
+func (si *softwareInterrupt) Name() string {
+	return si.name
+}
+
+// Value returns the number of edges counted since the board started.
+func (si *softwareInterrupt) Value(ctx context.Context, extra map[string]interface{}) (int64, error) {
+	return si.count.Load(), nil
+}
+
+// sample reads the input and counts an edge once a change has been stable for the debounce time.
+// It returns the tick for a change, if one was completed.
+func (si *softwareInterrupt) sample(now time.Time) (board.Tick, bool, error) {
+	state, err := si.pin.Get(context.Background(), nil)
+	if err != nil {
+		return board.Tick{}, false, err
+	}
+	if state == si.stable {
+		si.candidate = state
+		return board.Tick{}, false, nil
+	}
+	if state != si.candidate {
+		si.candidate = state
+		si.changedSince = now
+	}
+	if now.Sub(si.changedSince) < si.debounce {
+		return board.Tick{}, false, nil
+	}
+	si.stable = state
+	if (state && !si.rising) || (!state && !si.falling) {
+		return board.Tick{}, false, nil
+	}
+	si.count.Add(1)
+	return board.Tick{Name: si.name, High: state, TimestampNanosec: uint64(now.UnixNano())}, true, nil
+}
+
+// subscribe adds the ticks of the interrupt to queue until ctx is done.
+func (si *softwareInterrupt) subscribe(ctx context.Context, queue chan board.Tick) {
+	si.subscribersMu.Lock()
+	defer si.subscribersMu.Unlock()
+	si.subscribers = append(si.subscribers, tickSubscriber{ctx: ctx, queue: queue})
+}
+
+// publish adds a tick to the queue of every subscriber without waiting, so a slow stream never holds up the
+// sampler. The subscribers whose stream has ended are dropped. It returns the number of subscribers whose queue
+// was full and missed the tick.
+func (si *softwareInterrupt) publish(tick board.Tick) int {
+	si.subscribersMu.Lock()
+	defer si.subscribersMu.Unlock()
+	missed := 0
+	active := si.subscribers[:0]
+	for _, sub := range si.subscribers {
+		if sub.ctx.Err() != nil {
+			continue
+		}
+		select {
+		case sub.queue <- tick:
+		default:
+			missed++
+		}
+		active = append(active, sub)
+	}
+	si.subscribers = active
+	return missed
+}
+
+// addSoftwareInterrupts creates the configured software interrupts. The pins are kept with the board's
+// GPIO pins, so they are re-initialized when the configuration is reloaded.
+func (b *revolutionPiBoard) addSoftwareInterrupts(configs []SoftwareInterruptConfig) error {
+	for _, cfg := range configs {
+		pin, err := b.controlChip.GetGPIOPin(cfg.Name)
+		if err != nil {
+			return fmt.Errorf("software interrupt %s: %w", cfg.Name, err)
+		}
+		si, err := newSoftwareInterrupt(cfg, pin)
+		if err != nil {
+			return err
+		}
+		b.gpioPins[cfg.Name] = pin
+		b.softwareInterrupts[cfg.Name] = si
+		b.softwareInterruptNames = append(b.softwareInterruptNames, cfg.Name)
+	}
+	b.DigitalInterrupts = append(b.DigitalInterrupts, b.softwareInterruptNames...)
+	return nil
+}
+
+// startSoftwareInterrupts samples every software interrupt from a background worker until the board closes.
+func (b *revolutionPiBoard) startSoftwareInterrupts(interval time.Duration) {
+	interrupts := make([]*softwareInterrupt, 0, len(b.softwareInterrupts))
+	for _, si := range b.softwareInterrupts {
+		interrupts = append(interrupts, si)
+	}
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for utils.SelectContextOrWait(b.cancelCtx, interval) {
+			now := time.Now()
+			for _, si := range interrupts {
+				tick, ok, err := si.sample(now)
+				if err != nil {
+					b.logger.Debugf("failed to sample software interrupt %s: %v", si.name, err)
+					continue
+				}
+				if !ok {
+					continue
+				}
+				if missed := si.publish(tick); missed > 0 {
+					b.logger.Warnf("dropped a tick of software interrupt %s for %d streams that fell behind", si.name, missed)
+				}
+			}
+		}
+	}, b.activeBackgroundWorkers.Done)
+	b.logger.Infof("sampling %d software interrupts every %v", len(interrupts), interval)
+}
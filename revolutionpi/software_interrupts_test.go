@@ -0,0 +1,49 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+)
+
+func TestSoftwareInterruptSlowStream(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{
+		SimulateInputs:     true,
+		SoftwareInterrupts: []SoftwareInterruptConfig{{Name: "I_1"}},
+	})
+	if _, err := b.DoCommand(ctx, map[string]interface{}{stopIOKey: true}); err != nil {
+		t.Fatal(err)
+	}
+	interrupt, err := b.DigitalInterruptByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a stream that is never read must not hold up the sampling of the input
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := b.StreamTicks(streamCtx, []board.DigitalInterrupt{interrupt}, make(chan board.Tick), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := b.GPIOPinByName("I_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const edges = 5
+	for i := 0; i < edges; i++ {
+		for _, high := range []bool{true, false} {
+			if err := in.Set(ctx, high, nil); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if value, err := interrupt.Value(ctx, nil); err != nil || value != edges {
+		t.Errorf("counted %d, %v rising edges, expected %d", value, err, edges)
+	}
+}
@@ -0,0 +1,172 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/grpc"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+const revPiStatusName = "RevPiStatus"
+
+// StatusSensorModel is the model triplet for the rev-pi base module status sensor.
+var StatusSensorModel = resource.NewModel("viam-labs", "kunbus", "revolutionpi-status")
+
+// revPiStatusBits are the readings decoded from the bits of RevPiStatus, by bit position.
+var revPiStatusBits = []string{
+	"running",              // piControl is running
+	"unconfigured_modules", // at least one connected module is not configured in PiCtory
+	"modules_missing",      // at least one module configured in PiCtory is not connected
+	"size_mismatch",        // a module uses more or less of the process image than configured
+	"left_gateway",         // a gateway is connected on the left side
+	"right_gateway",        // a gateway is connected on the right side
+	"io_error",             // the communication with the IO modules failed
+}
+
+// statusVariable is a variable of the base module returned by the status sensor.
+type statusVariable struct {
+	name  string
+	key   string
+	typ   string
+	scale float64
+}
+
+// statusVariables are the variables of the Core and Connect base modules other than RevPiStatus. Not every base
+// module has all of them, the ones missing from the configuration are left out of the readings.
+var statusVariables = []statusVariable{
+	{name: "RevPiIOCycle", key: "io_cycle_ms", typ: typeU8},
+	{name: "RevPiIOErrorCount", key: "io_error_count", typ: typeU16},
+	{name: "RS485ErrorCnt", key: "rs485_error_count", typ: typeU16},
+	{name: "RS485ErrorLimit1", key: "rs485_error_limit_1", typ: typeU16},
+	{name: "RS485ErrorLimit2", key: "rs485_error_limit_2", typ: typeU16},
+	{name: "Core_Temperature", key: "temperature_c", typ: typeU8},
+	// Core_Frequency is in units of 10 MHz
+	{name: "Core_Frequency", key: "frequency_mhz", typ: typeU8, scale: 10},
+}
+
+// StatusSensorConfig is the config for the rev-pi base module status sensor.
+type StatusSensorConfig struct {
+	// Board is the revolution pi board whose base module is read.
+	Board string `json:"board"`
+}
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		StatusSensorModel,
+		resource.Registration[sensor.Sensor, *StatusSensorConfig]{Constructor: newStatusSensor})
+}
+
+// Validate validates the StatusSensorConfig.
+func (cfg *StatusSensorConfig) Validate(path string) ([]string, error) {
+	if cfg.Board == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "board")
+	}
+	return []string{cfg.Board}, nil
+}
+
+// statusSensor reports the health of the Revolution Pi from the variables of its base module.
+type statusSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+	// chip is the board's chip, shared with the board
+	chip *gpioChip
+	// variables are the variables found in the configuration, RevPiStatus first
+	variables []statusVariable
+	names     []string
+}
+
+func newStatusSensor(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	newConf, err := resource.NativeConfig[*StatusSensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	revPi, err := revolutionPiBoardFromDependencies(deps, newConf.Board)
+	if err != nil {
+		return nil, err
+	}
+	s := &statusSensor{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+		chip:   revPi.controlChip.acquire(),
+	}
+	if err := s.findVariables(); err != nil {
+		return nil, multierr.Combine(err, s.chip.release())
+	}
+	return s, nil
+}
+
+// findVariables looks up which of the base module variables are part of the configuration.
+func (s *statusSensor) findVariables() error {
+	s.chip.configMu.RLock()
+	defer s.chip.configMu.RUnlock()
+	status := SPIVariable{strVarName: char32(revPiStatusName)}
+	if err := s.chip.mapNameToAddress(&status); err != nil {
+		return fmt.Errorf("the configuration has no base module: %w", err)
+	}
+	s.variables = []statusVariable{{name: revPiStatusName, key: "status", typ: typeU8}}
+	for _, variable := range statusVariables {
+		pin := SPIVariable{strVarName: char32(variable.name)}
+		if err := s.chip.mapNameToAddress(&pin); err != nil {
+			s.logger.Debugf("base module has no %s: %v", variable.name, err)
+			continue
+		}
+		if pin.i16uLength/8 != variableTypeSizes[variable.typ] {
+			s.logger.Warnf("base module variable %s has %d bits, expected %s", variable.name, pin.i16uLength, variable.typ)
+			continue
+		}
+		s.variables = append(s.variables, variable)
+	}
+	for _, variable := range s.variables {
+		s.names = append(s.names, variable.name)
+	}
+	return nil
+}
+
+// Readings returns RevPiStatus, decoded into its bits, and the other variables of the base module.
+func (s *statusSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	s.chip.configMu.RLock()
+	defer s.chip.configMu.RUnlock()
+
+	pins, start, span, err := s.chip.readVariables(s.names)
+	if err != nil {
+		return nil, err
+	}
+	readings := map[string]interface{}{}
+	for i, pin := range pins {
+		variable := s.variables[i]
+		value, err := variableReading(SensorVariableConfig{Name: variable.name, Type: variable.typ, Scale: variable.scale},
+			pin, span[int64(pin.i16uAddress)-start:])
+		if err != nil {
+			return nil, err
+		}
+		readings[variable.key] = value
+	}
+	status := readings["status"].(int64)
+	for bit, key := range revPiStatusBits {
+		readings[key] = status&(1<<bit) != 0
+	}
+	return readings, nil
+}
+
+func (s *statusSensor) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return nil, grpc.UnimplementedError
+}
+
+func (s *statusSensor) Close(ctx context.Context) error {
+	return s.chip.release()
+}
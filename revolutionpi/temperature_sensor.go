@@ -0,0 +1,146 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/grpc"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+const (
+	unitCelsius    = "C"
+	unitFahrenheit = "F"
+)
+
+// TemperatureSensorModel is the model triplet for the rev-pi RTD temperature sensor.
+var TemperatureSensorModel = resource.NewModel("viam-labs", "kunbus", "revolutionpi-temperature")
+
+// TemperatureSensorConfig is the config for the rev-pi RTD temperature sensor.
+type TemperatureSensorConfig struct {
+	// Board is the revolution pi board the AIO module is connected to.
+	Board string `json:"board"`
+	// Pin is the RTD input, RTDValue_1 or RTDValue_2.
+	Pin string `json:"pin"`
+	// Unit is the unit of the temperature, "C" (the default) or "F".
+	Unit string `json:"unit,omitempty"`
+}
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		TemperatureSensorModel,
+		resource.Registration[sensor.Sensor, *TemperatureSensorConfig]{Constructor: newTemperatureSensor})
+}
+
+// Validate validates the TemperatureSensorConfig.
+func (cfg *TemperatureSensorConfig) Validate(path string) ([]string, error) {
+	if cfg.Board == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "board")
+	}
+	if cfg.Pin == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "pin")
+	}
+	switch cfg.Unit {
+	case "", unitCelsius, unitFahrenheit:
+	default:
+		return nil, resource.NewConfigValidationError(path,
+			fmt.Errorf("unknown unit %q, expected %q or %q", cfg.Unit, unitCelsius, unitFahrenheit))
+	}
+	return []string{cfg.Board}, nil
+}
+
+// temperatureSensor reads the temperature of an RTD input of an AIO module.
+type temperatureSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger     logging.Logger
+	pin        *analogPin
+	chip       *gpioChip
+	fahrenheit bool
+}
+
+func newTemperatureSensor(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	newConf, err := resource.NativeConfig[*TemperatureSensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	revPi, err := revolutionPiBoardFromDependencies(deps, newConf.Board)
+	if err != nil {
+		return nil, err
+	}
+	analog, err := revPi.AnalogByName(newConf.Pin)
+	if err != nil {
+		return nil, err
+	}
+	pin, ok := analog.(*analogPin)
+	if !ok || !pin.isRTDInput() {
+		return nil, fmt.Errorf("temperature sensor pin %s must be an RTD input", newConf.Pin)
+	}
+	s := &temperatureSensor{
+		Named:      conf.ResourceName().AsNamed(),
+		logger:     logger,
+		pin:        pin,
+		chip:       pin.ControlChip.acquire(),
+		fahrenheit: newConf.Unit == unitFahrenheit,
+	}
+	if _, err := s.Readings(ctx, nil); err != nil {
+		return nil, multierr.Combine(err, s.chip.release())
+	}
+	return s, nil
+}
+
+// Readings returns the temperature, the configuration of the RTD input and whether the module reports an error.
+// The module sets RTDStatus if the sensor is broken, disconnected or out of range, in which case the
+// temperature is left out.
+func (s *temperatureSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	s.chip.configMu.RLock()
+	defer s.chip.configMu.RUnlock()
+	if !s.pin.initialized || !s.pin.isRTDInput() {
+		return nil, errors.New("the RTD input is no longer part of the configuration")
+	}
+	value, status, err := s.pin.readRTD()
+	if err != nil {
+		return nil, err
+	}
+	readings := map[string]interface{}{
+		"sensor_break": status != 0,
+		"status":       int64(status),
+		"type":         s.pin.rtd.sensorType,
+		"wires":        s.pin.rtd.wires,
+		"unit":         unitCelsius,
+	}
+	if s.fahrenheit {
+		readings["unit"] = unitFahrenheit
+	}
+	if status != 0 {
+		return readings, nil
+	}
+	temperature := s.pin.rtd.celsius(value)
+	if s.fahrenheit {
+		temperature = temperature*9/5 + 32
+	}
+	readings["temperature"] = temperature
+	return readings, nil
+}
+
+func (s *temperatureSensor) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return nil, grpc.UnimplementedError
+}
+
+func (s *temperatureSensor) Close(ctx context.Context) error {
+	return s.chip.release()
+}
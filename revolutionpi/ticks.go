@@ -0,0 +1,133 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/utils"
+)
+
+// defaultTickPollInterval is how often counters are polled for ticks when no interval is configured.
+const defaultTickPollInterval = 5 * time.Millisecond
+
+// tickCounter tracks the last value of a counter watched by StreamTicks.
+type tickCounter struct {
+	pin  *counterPin
+	last uint32
+}
+
+// newTicks returns the number of edges counted since the last poll. A counter that went down was reset,
+// unless it wrapped around, and only counts from its new value.
+func (c *tickCounter) newTicks(value uint32) uint32 {
+	last := c.last
+	c.last = value
+	if value < last && last-value < 1<<31 {
+		return 0
+	}
+	return value - last
+}
+
+// StreamTicks starts a stream of digital interrupt ticks. The counters of the interrupts are polled from a
+// background worker, and every increment is sent as a tick until ctx is done or the board closes.
+// Software interrupts send a tick for every edge they count.
+func (b *revolutionPiBoard) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt,
+	ch chan board.Tick, extra map[string]interface{},
+) error {
+	counters := make([]*tickCounter, 0, len(interrupts))
+	var software []*softwareInterrupt
+	for _, interrupt := range interrupts {
+		switch interrupt := interrupt.(type) {
+		case *diWrapper:
+			value, err := interrupt.pin.Value()
+			if err != nil {
+				return err
+			}
+			counters = append(counters, &tickCounter{pin: interrupt.pin, last: value})
+		case *softwareInterrupt:
+			software = append(software, interrupt)
+		default:
+			return fmt.Errorf("digital interrupt %s is not a revolution pi interrupt", interrupt.Name())
+		}
+	}
+	// software interrupts are already sampled by the board, so they only queue their ticks for ch
+	if len(software) > 0 {
+		queue := make(chan board.Tick, softwareTickBuffer)
+		for _, si := range software {
+			si.subscribe(ctx, queue)
+		}
+		b.forwardTicks(ctx, queue, ch)
+	}
+	if len(counters) == 0 {
+		return nil
+	}
+
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for utils.SelectContextOrWait(ctx, b.tickPollInterval) {
+			if b.cancelCtx.Err() != nil {
+				return
+			}
+			for _, counter := range counters {
+				if !b.sendTicks(ctx, counter, ch) {
+					return
+				}
+			}
+		}
+	}, b.activeBackgroundWorkers.Done)
+	return nil
+}
+
+// forwardTicks sends the ticks queued by software interrupts to ch from a background worker, until ctx is done
+// or the board closes.
+func (b *revolutionPiBoard) forwardTicks(ctx context.Context, queue <-chan board.Tick, ch chan board.Tick) {
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for {
+			var tick board.Tick
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.cancelCtx.Done():
+				return
+			case tick = <-queue:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.cancelCtx.Done():
+				return
+			case ch <- tick:
+			}
+		}
+	}, b.activeBackgroundWorkers.Done)
+}
+
+// sendTicks polls a counter and sends a tick for every edge it counted. It returns false once the stream should stop.
+func (b *revolutionPiBoard) sendTicks(ctx context.Context, counter *tickCounter, ch chan board.Tick) bool {
+	value, err := counter.pin.Value()
+	if err != nil {
+		b.logger.Debugf("failed to poll digital interrupt %s: %v", counter.pin.pinName, err)
+		return true
+	}
+	timestamp := uint64(time.Now().UnixNano())
+	tick := board.Tick{
+		Name:             counter.pin.pinName,
+		High:             counter.pin.inputMode != inputModeCounterFalling,
+		TimestampNanosec: timestamp,
+	}
+	for n := counter.newTicks(value); n > 0; n-- {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-b.cancelCtx.Done():
+			return false
+		case ch <- tick:
+		}
+	}
+	return true
+}
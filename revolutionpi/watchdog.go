@@ -0,0 +1,86 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"go.viam.com/utils"
+)
+
+// setOutputWatchdog arms the piControl output watchdog for the chip's handle. If nothing is written
+// to the handle for the given timeout, piControl sets every output to 0. A timeout of 0 disarms the watchdog.
+func (g *gpioChip) setOutputWatchdog(timeout time.Duration) error {
+	timeoutMs := uint32(timeout.Milliseconds())
+	g.logger.Debugf("setting output watchdog to %d ms", timeoutMs)
+	//nolint:gosec
+	err := g.ioCtl(uintptr(kbSetOutputWatchdog), unsafe.Pointer(&timeoutMs))
+	if err != nil {
+		return fmt.Errorf("failed to set output watchdog on %v: %w", g.dev, err)
+	}
+	return nil
+}
+
+// refreshOutputWatchdog restarts the output watchdog timer. piControl restarts the timer when the handle writes to
+// the process image, so the current value of one output byte is written back: the byte the board wrote last, or the
+// first output byte of the modules before the board wrote anything. The byte is read and written while holding
+// writeMu, so no write of the board is lost, but a PLC runtime writing the same byte in between loses its change.
+// The caller holds configMu for reading.
+func (g *gpioChip) refreshOutputWatchdog() error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	address := g.lastWrite - 1
+	if address < 0 {
+		address = firstOutput(g.dioDevices, g.aioDevices)
+	}
+	if address < 0 {
+		return errors.New("there is no output to refresh the output watchdog with")
+	}
+	b := make([]byte, 1)
+	if _, err := g.image.ReadAt(b, address); err != nil {
+		return g.withDriverMessage(err)
+	}
+	return g.writeImageLocked(address, b)
+}
+
+// firstOutput returns the address of the first output byte of the devices, or -1 if they have no outputs.
+func firstOutput(deviceLists ...[]SDeviceInfo) int64 {
+	for _, devices := range deviceLists {
+		for _, dev := range devices {
+			if dev.i16uOutputLength > 0 {
+				return int64(dev.i16uOutputOffset)
+			}
+		}
+	}
+	return -1
+}
+
+// startOutputWatchdog arms the output watchdog and keeps refreshing it from a background worker until the board closes.
+func (b *revolutionPiBoard) startOutputWatchdog(timeout time.Duration) error {
+	if err := b.controlChip.setOutputWatchdog(timeout); err != nil {
+		return err
+	}
+	b.outputWatchdog = timeout
+	// refresh several times per timeout so a single late wakeup does not trip the watchdog
+	refreshPeriod := timeout / 4
+	if refreshPeriod < time.Millisecond {
+		refreshPeriod = time.Millisecond
+	}
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		for utils.SelectContextOrWait(b.cancelCtx, refreshPeriod) {
+			b.controlChip.configMu.RLock()
+			err := b.controlChip.refreshOutputWatchdog()
+			b.controlChip.configMu.RUnlock()
+			if err != nil {
+				b.logger.Warnf("failed to refresh output watchdog: %v", err)
+			}
+		}
+	}, b.activeBackgroundWorkers.Done)
+	b.logger.Infof("output watchdog armed with a timeout of %v", timeout)
+	return nil
+}
@@ -0,0 +1,299 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	writeManyKey = "writeMany"
+	readManyKey  = "readMany"
+)
+
+// byteChange is the part of a process image byte changed by a transaction. Only the bits set in mask are written.
+type byteChange struct {
+	mask  byte
+	value byte
+}
+
+// byteRange is a run of consecutive bytes changed by a transaction.
+type byteRange struct {
+	address int64
+	changes []byteChange
+}
+
+// imageTransaction collects changes to several variables, so they can be written to the process image together.
+type imageTransaction struct {
+	changes map[int64]byteChange
+}
+
+func newImageTransaction() *imageTransaction {
+	return &imageTransaction{changes: map[int64]byteChange{}}
+}
+
+// set adds the new value of a variable to the transaction.
+func (t *imageTransaction) set(pin SPIVariable, value int64) {
+	address := int64(pin.i16uAddress)
+	if pin.i16uLength == 1 {
+		change := t.changes[address]
+		bit := byte(1) << pin.i8uBit
+		change.mask |= bit
+		if value != 0 {
+			change.value |= bit
+		} else {
+			change.value &^= bit
+		}
+		t.changes[address] = change
+		return
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(value))
+	for i := int64(0); i < int64(pin.i16uLength/8); i++ {
+		t.changes[address+i] = byteChange{mask: 0xff, value: b[i]}
+	}
+}
+
+// ranges merges the changed bytes into the smallest number of consecutive byte ranges.
+func (t *imageTransaction) ranges() []byteRange {
+	addresses := make([]int64, 0, len(t.changes))
+	for address := range t.changes {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+
+	var ranges []byteRange
+	for _, address := range addresses {
+		last := len(ranges) - 1
+		if last >= 0 && ranges[last].address+int64(len(ranges[last].changes)) == address {
+			ranges[last].changes = append(ranges[last].changes, t.changes[address])
+			continue
+		}
+		ranges = append(ranges, byteRange{address: address, changes: []byteChange{t.changes[address]}})
+	}
+	return ranges
+}
+
+// applyTransaction writes every range of the transaction with a single write while holding writeMu, so no other
+// write of the board comes in between. Only the changed bytes are written. Bytes of which only some bits change
+// are read from the process image first, bypassing the snapshot, and written with the other bits as they are.
+func (g *gpioChip) applyTransaction(t *imageTransaction) error {
+	ranges := t.ranges()
+	if len(ranges) == 0 {
+		return nil
+	}
+	if g.exported != nil {
+		return g.exported.apply(ranges)
+	}
+
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	for _, r := range ranges {
+		merged := make([]byte, len(r.changes))
+		for i, change := range r.changes {
+			if change.mask != 0xff {
+				if _, err := g.image.ReadAt(merged[i:i+1], r.address+int64(i)); err != nil {
+					return g.withDriverMessage(err)
+				}
+			}
+			merged[i] = merged[i]&^change.mask | change.value&change.mask
+		}
+		if err := g.writeImageLocked(r.address, merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply writes every range to the application image at once, so they are pushed in the same cycle.
+// Nothing is written unless every changed byte belongs to an allow-listed variable.
+func (e *exportedOutputs) apply(ranges []byteRange) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range ranges {
+		for i, change := range r.changes {
+			address := r.address + int64(i)
+			if !e.allowsLocked(address, change.mask) {
+				return fmt.Errorf("output at address %d is not an exported output", address)
+			}
+		}
+	}
+	for _, r := range ranges {
+		for i, change := range r.changes {
+			b := &e.image[r.address+int64(i)]
+			*b = *b&^change.mask | change.value&change.mask
+		}
+	}
+	return nil
+}
+
+// allowsLocked checks whether the bits in mask of the byte at address belong to allow-listed variables.
+func (e *exportedOutputs) allowsLocked(address int64, mask byte) bool {
+	for _, v := range e.allowed {
+		start := int64(v.i16uAddress)
+		if v.i16uLength >= 8 && address >= start && address < start+int64(v.i16uLength/8) {
+			return true
+		}
+		if v.i16uLength == 1 && address == start {
+			mask &^= 1 << v.i8uBit
+		}
+	}
+	return mask == 0
+}
+
+// variableValue converts a DoCommand value to the raw value of a variable.
+func variableValue(pin SPIVariable, value interface{}) (int64, error) {
+	if pin.i16uLength == 1 {
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return 1, nil
+			}
+			return 0, nil
+		case float64:
+			if v != 0 && v != 1 {
+				return 0, fmt.Errorf("expected a bool or 0/1 for %s, got %v", str32(pin.strVarName), value)
+			}
+			return int64(v), nil
+		default:
+			return 0, fmt.Errorf("expected a bool for %s, got %v", str32(pin.strVarName), value)
+		}
+	}
+	v, ok := value.(float64)
+	if !ok || v != math.Trunc(v) {
+		return 0, fmt.Errorf("expected an integer for %s, got %v", str32(pin.strVarName), value)
+	}
+	// signed and unsigned values are both accepted, the process image stores them the same way
+	bits := int(pin.i16uLength)
+	if v < -math.Exp2(float64(bits-1)) || v > math.Exp2(float64(bits))-1 {
+		return 0, fmt.Errorf("value %v does not fit in the %d bits of %s", value, bits, str32(pin.strVarName))
+	}
+	return int64(v), nil
+}
+
+// manyNames reads the variable names requested by readMany.
+func manyNames(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error performing %s: expected a list of names got %v", readManyKey, value)
+	}
+	names := make([]string, 0, len(list))
+	for _, entry := range list {
+		name, ok := entry.(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string got %v", readManyKey, entry)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// readVariables reads several variables with a single read of the process image, so every value comes from the
+// same cycle. It returns the variables and the bytes from the first to the last of them, starting at start.
+// The caller holds configMu for reading.
+func (g *gpioChip) readVariables(names []string) ([]SPIVariable, int64, []byte, error) {
+	pins := make([]SPIVariable, 0, len(names))
+	start, end := int64(processImageSize), int64(0)
+	for _, name := range names {
+		pin := SPIVariable{strVarName: char32(name)}
+		if err := g.mapNameToAddress(&pin); err != nil {
+			return nil, 0, nil, err
+		}
+		pins = append(pins, pin)
+		start = min(start, int64(pin.i16uAddress))
+		end = max(end, int64(pin.i16uAddress)+int64(max(pin.i16uLength/8, 1)))
+	}
+
+	span := make([]byte, end-start)
+	if _, err := g.readAt(span, start); err != nil {
+		return nil, 0, nil, err
+	}
+	return pins, start, span, nil
+}
+
+// writeMany writes several outputs, possibly on different modules, as one update of the process image.
+// The value is a list of {"name": ..., "value": ...} entries.
+func (b *revolutionPiBoard) writeMany(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("error performing %s: expected a list of {name, value} got %v", writeManyKey, value)
+	}
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+
+	deviceInfoList, err := b.controlChip.deviceInfoList()
+	if err != nil {
+		return err
+	}
+	transaction := newImageTransaction()
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("error performing %s: expected {name, value} got %v", writeManyKey, entry)
+		}
+		name, ok := fields["name"].(string)
+		if !ok {
+			return fmt.Errorf("error performing %s: expected string name got %v", writeManyKey, fields["name"])
+		}
+		pin := SPIVariable{strVarName: char32(name)}
+		if err := b.controlChip.mapNameToAddress(&pin); err != nil {
+			return err
+		}
+		if !isOutput(pin, deviceInfoList) {
+			return fmt.Errorf("error performing %s: %s is not an output", writeManyKey, name)
+		}
+		raw, err := variableValue(pin, fields["value"])
+		if err != nil {
+			return fmt.Errorf("error performing %s: %w", writeManyKey, err)
+		}
+		transaction.set(pin, raw)
+	}
+	if err := b.controlChip.applyTransaction(transaction); err != nil {
+		return err
+	}
+	resp[writeManyKey] = len(entries)
+	return nil
+}
+
+// readMany reads several variables from the same snapshot of the process image.
+func (b *revolutionPiBoard) readMany(ctx context.Context, value interface{}, resp map[string]interface{}) error {
+	names, err := manyNames(value)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		resp[readManyKey] = map[string]interface{}{}
+		return nil
+	}
+	b.controlChip.configMu.RLock()
+	defer b.controlChip.configMu.RUnlock()
+
+	pins, start, span, err := b.controlChip.readVariables(names)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	for i, pin := range pins {
+		offset := int64(pin.i16uAddress) - start
+		if pin.i16uLength == 1 {
+			values[names[i]] = (span[offset]>>pin.i8uBit)&1 == 1
+			continue
+		}
+		size := int(pin.i16uLength / 8)
+		values[names[i]], err = readFromBuffer(span[offset:offset+int64(size)], size)
+		if err != nil {
+			return err
+		}
+	}
+	resp[readManyKey] = values
+	if b.controlChip.cache != nil {
+		resp[snapshotTimeKey] = b.controlChip.cache.snapshotTime().Format(time.RFC3339Nano)
+	}
+	return nil
+}
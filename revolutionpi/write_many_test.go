@@ -0,0 +1,67 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"testing"
+)
+
+func TestImageTransactionRanges(t *testing.T) {
+	tx := newImageTransaction()
+	tx.set(SPIVariable{i16uAddress: 10, i8uBit: 1, i16uLength: 1}, 1)
+	tx.set(SPIVariable{i16uAddress: 10, i8uBit: 3, i16uLength: 1}, 0)
+	tx.set(SPIVariable{i16uAddress: 11, i16uLength: 16}, 0x1234)
+	tx.set(SPIVariable{i16uAddress: 20, i16uLength: 8}, 7)
+	ranges := tx.ranges()
+	if len(ranges) != 2 {
+		t.Fatalf("expected 2 ranges, got %v", ranges)
+	}
+	if ranges[0].address != 10 || len(ranges[0].changes) != 3 || ranges[1].address != 20 || len(ranges[1].changes) != 1 {
+		t.Errorf("unexpected ranges %v", ranges)
+	}
+	if ranges[0].changes[0] != (byteChange{mask: 0x0a, value: 0x02}) {
+		t.Errorf("unexpected bit change %v", ranges[0].changes[0])
+	}
+}
+
+func TestWriteManyLeavesOtherBytes(t *testing.T) {
+	ctx := context.Background()
+	b := newSimulatedBoard(t, &Config{})
+	dio := b.controlChip.dioDevices[0]
+	aio := b.controlChip.aioDevices[0]
+	// the DIO configuration and the AIO inputs lie between O_1 and OutputValue_1
+	between := make([]byte, aio.i16uOutputOffset-dio.i16uOutputOffset-1)
+	for i := range between {
+		between[i] = 0x5a
+	}
+	if _, err := b.controlChip.image.WriteAt(between, int64(dio.i16uOutputOffset+1)); err != nil {
+		t.Fatal(err)
+	}
+	writeVariable(t, b.controlChip, "O_2", []byte{0x02})
+
+	req := map[string]interface{}{writeManyKey: []interface{}{
+		map[string]interface{}{"name": "O_1", "value": true},
+		map[string]interface{}{"name": "OutputValue_1", "value": 5000.0},
+	}}
+	if _, err := b.DoCommand(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := b.DoCommand(ctx, map[string]interface{}{readManyKey: []interface{}{"O_1", "O_2", "OutputValue_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := resp[readManyKey].(map[string]interface{})
+	if values["O_1"] != true || values["O_2"] != true {
+		t.Errorf("expected O_1 and O_2 to be set, got %v", values)
+	}
+	data := make([]byte, len(between))
+	if _, err := b.controlChip.image.ReadAt(data, int64(dio.i16uOutputOffset+1)); err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		if data[i] != 0x5a {
+			t.Fatalf("byte %d after O_1 changed from 0x5a to %#x", i, data[i])
+		}
+	}
+}